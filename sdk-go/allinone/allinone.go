@@ -39,12 +39,24 @@ func NewSandbox(ctx context.Context, opts ...sandbox.Option) (*Sandbox, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newSandbox(client), nil
+}
+
+func Attach(ctx context.Context, id string, opts ...sandbox.Option) (*Sandbox, error) {
+	client, err := sandbox.Attach(ctx, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newSandbox(client), nil
+}
+
+func newSandbox(client *sandbox.Sandbox) *Sandbox {
 	return &Sandbox{
 		Sandbox: client,
 		codeide: &codeide.Sandbox{Sandbox: client},
 		hostmcp: &hostmcp.Sandbox{Sandbox: client},
 		desktop: &desktop.Sandbox{Sandbox: client},
-	}, nil
+	}
 }
 
 // codeide
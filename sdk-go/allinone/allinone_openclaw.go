@@ -4,14 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"connectrpc.com/connect"
 	"github.com/a3tai/openclaw-go/chatcompletions"
 	"github.com/a3tai/openclaw-go/gateway"
 	"github.com/a3tai/openclaw-go/identity"
@@ -19,6 +17,7 @@ import (
 	"github.com/a3tai/openclaw-go/protocol"
 	"github.com/llm-infra/secvirt/sdk-go/allinone/openclaw"
 	"github.com/llm-infra/secvirt/sdk-go/desktop"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/commands"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
 	"github.com/mel2oo/go-dkit/json"
@@ -33,6 +32,9 @@ const (
 	openClawPairedFile  = "devices/paired.json"
 )
 
+// openClawTag 标记 openclaw gateway 进程，SDK 重启后也能找到
+const openClawTag = "openclaw-gateway"
+
 var ErrOpenClawNotRunning = errors.New("openclaw is not running")
 
 const (
@@ -48,7 +50,7 @@ type Claw struct {
 	clientInfo     protocol.ClientInfo
 	scopes         []protocol.Scope
 
-	pid int
+	pids []int
 }
 
 func (s *Sandbox) InitOpenClaw(ctx context.Context, opts ...desktop.Option) error {
@@ -56,11 +58,8 @@ func (s *Sandbox) InitOpenClaw(ctx context.Context, opts ...desktop.Option) erro
 
 	// 检查配置
 	exist, err := s.Filesystem().Exist(ctx, configDir)
-	if err != nil {
-		var connectErr *connect.Error
-		if !errors.As(err, &connectErr) || connectErr.Code() != connect.CodeNotFound {
-			return err
-		}
+	if err != nil && !errors.Is(err, sandbox.ErrNotFound) {
+		return err
 	}
 
 	if !exist {
@@ -100,16 +99,18 @@ func (s *Sandbox) RestartOpenClaw(ctx context.Context, opts ...desktop.Option) e
 		o(opt)
 	}
 
-	if s.claw.pid != 0 {
-		s.Cmd().Run(ctx, fmt.Sprintf("kill -9 %d", s.claw.pid), nil, "", false)
-		s.claw.pid = 0
+	if err := s.stopOpenClaw(ctx); err != nil {
+		return err
 	}
 
-	handle, err := s.Cmd().Start(ctx,
-		"openclaw gateway",
-		opt.Envs(),
-		opt.Cwd(),
-		opt.Stdin())
+	handle, err := s.Cmd().Exec(ctx, commands.Spec{
+		Command: "openclaw gateway",
+		Login:   true,
+		Envs:    opt.Envs(),
+		Cwd:     opt.Cwd(),
+		Stdin:   opt.Stdin(),
+		Tag:     openClawTag,
+	})
 	if err != nil {
 		return err
 	}
@@ -215,16 +216,16 @@ func (s *Sandbox) loadOpenClawRuntime(ctx context.Context) (*Claw, error) {
 }
 
 func (s *Sandbox) attachOpenClawIfRunning(ctx context.Context) (bool, error) {
-	pid, err := s.findOpenClawPIDs(ctx)
+	pids, err := s.findOpenClawPIDs(ctx)
 	if err != nil {
 		return false, err
 	}
 
-	s.claw.pid = pid
+	s.claw.pids = pids
 
 	client, err := s.ClawClient(ctx)
 	if err != nil {
-		s.claw.pid = 0
+		s.claw.pids = nil
 		return false, nil
 	}
 	defer client.Close()
@@ -232,17 +233,75 @@ func (s *Sandbox) attachOpenClawIfRunning(ctx context.Context) (bool, error) {
 	return true, nil
 }
 
-func (s *Sandbox) findOpenClawPIDs(ctx context.Context) (int, error) {
-	return retryFindOpenClawPID(ctx, func() (int, error) {
+func (s *Sandbox) findOpenClawPIDs(ctx context.Context) ([]int, error) {
+	return retryFindOpenClawPID(ctx, func() ([]int, error) {
+		procs, err := s.Cmd().List(ctx, commands.WithTag(openClawTag))
+		if err != nil {
+			return nil, err
+		}
+		if len(procs) > 0 {
+			pids := make([]int, 0, len(procs))
+			for _, proc := range procs {
+				pids = append(pids, int(proc.Pid()))
+			}
+			return pids, nil
+		}
+
+		// 兼容旧版本 SDK 启动的、没有 tag 的 gateway
 		res, err := s.Cmd().Run(ctx, "pgrep -f openclaw-gateway", nil, "", false)
 		if err != nil {
-			return 0, err
+			return nil, err
+		}
+		if res.ExitCode != 0 {
+			return nil, ErrOpenClawNotRunning
 		}
 
-		return strconv.Atoi(strings.TrimSpace(res.Stdout))
+		return parsePIDs(res.Stdout)
 	})
 }
 
+// parsePIDs parses the pids pgrep prints, one per line.
+func parsePIDs(out string) ([]int, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return nil, ErrOpenClawNotRunning
+	}
+
+	pids := make([]int, 0, len(fields))
+	for _, field := range fields {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("parse pgrep output: %w", err)
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+func (s *Sandbox) stopOpenClaw(ctx context.Context) error {
+	procs, err := s.Cmd().List(ctx, commands.WithTag(openClawTag))
+	if err != nil {
+		return fmt.Errorf("stop openclaw: %w", err)
+	}
+
+	if len(procs) > 0 {
+		err = s.Cmd().Terminate(ctx, commands.ByTag(openClawTag), time.Second,
+			commands.WithProcessGroup())
+	} else if len(s.claw.pids) > 0 {
+		// 旧版本 SDK 启动的 gateway 没有 tag
+		args := make([]string, 0, len(s.claw.pids))
+		for _, pid := range s.claw.pids {
+			args = append(args, strconv.Itoa(pid))
+		}
+		_, err = s.Cmd().Run(ctx, "kill -9 "+strings.Join(args, " "), nil, "", false)
+	}
+	if err != nil {
+		return fmt.Errorf("stop openclaw: %w", err)
+	}
+	s.claw.pids = nil
+	return nil
+}
+
 func (s *Sandbox) readOpenClawFile(ctx context.Context, path string) ([]byte, error) {
 	return retryOpenClawRead(ctx, func() ([]byte, error) {
 		return s.Filesystem().Read(ctx, path)
@@ -277,19 +336,19 @@ func retryOpenClawRead(ctx context.Context, read func() ([]byte, error)) ([]byte
 	return nil, lastErr
 }
 
-func retryFindOpenClawPID(ctx context.Context, find func() (int, error)) (int, error) {
+func retryFindOpenClawPID(ctx context.Context, find func() ([]int, error)) ([]int, error) {
 	var lastErr error
 	for attempt := 0; attempt < openClawMaxAttempts; attempt++ {
 		if err := ctx.Err(); err != nil {
-			return 0, err
+			return nil, err
 		}
 
-		pid, err := find()
+		pids, err := find()
 		if err == nil {
-			return pid, nil
+			return pids, nil
 		}
 		if !isRetryableOpenClawReadError(err) {
-			return 0, err
+			return nil, err
 		}
 
 		lastErr = err
@@ -298,23 +357,20 @@ func retryFindOpenClawPID(ctx context.Context, find func() (int, error)) (int, e
 		select {
 		case <-ctx.Done():
 			timer.Stop()
-			return 0, ctx.Err()
+			return nil, ctx.Err()
 		case <-timer.C:
 		}
 	}
 
-	return 0, lastErr
+	return nil, lastErr
 }
 
+// isRetryableOpenClawReadError also retries ErrNotFound: the gateway writes
+// files such as devices/paired.json only once it started.
 func isRetryableOpenClawReadError(err error) bool {
-	if err == nil {
-		return false
-	}
-	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-		return false
-	}
-
-	return true
+	return errors.Is(err, ErrOpenClawNotRunning) ||
+		errors.Is(err, sandbox.ErrNotFound) ||
+		sandbox.IsRetryable(err)
 }
 
 /*******************************/
@@ -333,8 +389,7 @@ func (s *Sandbox) ClawClient(ctx context.Context) (*gateway.Client, error) {
 	)
 
 	for i := 0; i < 3; i++ {
-		if err := client.Connect(ctx,
-			fmt.Sprintf("ws://%s:%d", s.ProxyHost, s.ProxyPort)); err == nil {
+		if err := client.Connect(ctx, s.WebSocketURL("/")); err == nil {
 			break
 		}
 		time.Sleep(time.Second * time.Duration(i))
@@ -350,14 +405,9 @@ func (s *Sandbox) ChatClient(opts ...ClawOption) *chatcompletions.Client {
 	}
 
 	return &chatcompletions.Client{
-		BaseURL: s.ProxyBaseURL(),
-		Token:   s.claw.token,
-		HTTPClient: &http.Client{
-			Transport: spec.NewHeaderRoundTripper(
-				spec.GenSandboxHeader(s.claw.port, s.Name, ""),
-				http.DefaultTransport,
-			),
-		},
+		BaseURL:    s.ProxyBaseURL(),
+		Token:      s.claw.token,
+		HTTPClient: s.HTTPClient(s.claw.port),
 		AgentID:    opt.agentID,
 		SessionKey: opt.sessionKey,
 	}
@@ -370,14 +420,9 @@ func (s *Sandbox) ResponsesClient(opts ...ClawOption) *openresponses.Client {
 	}
 
 	return &openresponses.Client{
-		BaseURL: s.ProxyBaseURL(),
-		Token:   s.claw.token,
-		HTTPClient: &http.Client{
-			Transport: spec.NewHeaderRoundTripper(
-				spec.GenSandboxHeader(s.claw.port, s.Name, ""),
-				http.DefaultTransport,
-			),
-		},
+		BaseURL:    s.ProxyBaseURL(),
+		Token:      s.claw.token,
+		HTTPClient: s.HTTPClient(s.claw.port),
 		AgentID:    opt.agentID,
 		SessionKey: opt.sessionKey,
 	}
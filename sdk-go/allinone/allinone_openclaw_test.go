@@ -106,6 +106,22 @@ func TestRetryOpenClawReadRetriesTransientEOF(t *testing.T) {
 	assert.Equal(t, 3, attempts)
 }
 
+func TestRetryOpenClawReadRetriesNotFound(t *testing.T) {
+	attempts := 0
+
+	data, err := retryOpenClawRead(t.Context(), func() ([]byte, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, fmt.Errorf("read devices/paired.json: %w", sandbox.ErrNotFound)
+		}
+		return []byte("{}"), nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte("{}"), data)
+	assert.Equal(t, 3, attempts)
+}
+
 func TestRetryOpenClawReadStopsOnNonRetryableError(t *testing.T) {
 	attempts := 0
 	wantErr := errors.New("permission denied")
@@ -122,16 +138,16 @@ func TestRetryOpenClawReadStopsOnNonRetryableError(t *testing.T) {
 func TestRetryFindOpenClawPIDRetriesNotRunning(t *testing.T) {
 	attempts := 0
 
-	pid, err := retryFindOpenClawPID(t.Context(), func() (int, error) {
+	pids, err := retryFindOpenClawPID(t.Context(), func() ([]int, error) {
 		attempts++
 		if attempts < 3 {
-			return 0, ErrOpenClawNotRunning
+			return nil, ErrOpenClawNotRunning
 		}
-		return 1234, nil
+		return []int{1234}, nil
 	})
 
 	require.NoError(t, err)
-	assert.Equal(t, 1234, pid)
+	assert.Equal(t, []int{1234}, pids)
 	assert.Equal(t, 3, attempts)
 }
 
@@ -139,11 +155,22 @@ func TestRetryFindOpenClawPIDStopsOnNonRetryableError(t *testing.T) {
 	attempts := 0
 	wantErr := errors.New("command failed")
 
-	_, err := retryFindOpenClawPID(t.Context(), func() (int, error) {
+	_, err := retryFindOpenClawPID(t.Context(), func() ([]int, error) {
 		attempts++
-		return 0, wantErr
+		return nil, wantErr
 	})
 
 	require.ErrorIs(t, err, wantErr)
 	assert.Equal(t, 1, attempts)
 }
+
+func TestParsePIDs(t *testing.T) {
+	pids, err := parsePIDs("1234\n5678\n")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1234, 5678}, pids)
+
+	_, err = parsePIDs("\n")
+	assert.ErrorIs(t, err, ErrOpenClawNotRunning)
+	_, err = parsePIDs("12ab\n")
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+// Command envd is the agent that runs inside a sandbox. It serves the process
+// and filesystem connect services the SDK talks to through the proxy.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/llm-infra/secvirt/sdk-go/internal/envd"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	var (
+		addr       = flag.String("addr", fmt.Sprintf(":%d", spec.DefaultEnvdPort), "listen address")
+		switchUser = flag.Bool("switch-user", os.Geteuid() == 0, "run processes as the X-USER user, requires root")
+		keepAlive  = flag.Duration("keepalive", 30*time.Second, "keepalive interval of process and watch streams")
+	)
+	flag.Parse()
+
+	opts := []envd.Option{envd.WithKeepAlive(*keepAlive)}
+	if *switchUser {
+		opts = append(opts, envd.WithSwitchUser())
+	}
+	svc := envd.New(opts...)
+	defer svc.Close()
+
+	// Connect streams are served over h2c as well as HTTP/1.1.
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           svc.Handler(),
+		Protocols:         &protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	logrus.Infof("envd listening on %s", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logrus.Fatalf("envd: %v", err)
+	}
+}
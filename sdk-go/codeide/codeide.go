@@ -27,6 +27,15 @@ func NewSandbox(ctx context.Context, opts ...sandbox.Option) (*Sandbox, error) {
 	return &Sandbox{Sandbox: client}, nil
 }
 
+func Attach(ctx context.Context, id string, opts ...sandbox.Option) (*Sandbox, error) {
+	client, err := sandbox.Attach(ctx, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sandbox{Sandbox: client}, nil
+}
+
 func (s *Sandbox) Packages(ctx context.Context, lang string) ([]PackagesResponse, error) {
 	resp, err := s.ProxyRequest(ctx, DefaultCodeIDEPort).
 		SetResult([]PackagesResponse{}).
@@ -2,8 +2,8 @@ package codeide
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -71,7 +71,7 @@ func TestSandboxConcurrency(t *testing.T) {
 					sandbox.WithHost("192.168.134.142"),
 				)
 				if err != nil {
-					if strings.Contains(err.Error(), "container waitting") {
+					if errors.Is(err, sandbox.ErrNotReady) {
 						time.Sleep(time.Second) // 等待再试
 						continue
 					}
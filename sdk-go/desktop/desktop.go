@@ -31,6 +31,15 @@ func NewSandbox(ctx context.Context, opts ...sandbox.Option) (*Sandbox, error) {
 	return &Sandbox{Sandbox: client}, nil
 }
 
+func Attach(ctx context.Context, id string, opts ...sandbox.Option) (*Sandbox, error) {
+	client, err := sandbox.Attach(ctx, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sandbox{Sandbox: client}, nil
+}
+
 func checkZipRootDir(ctx context.Context, name string, data []byte) (string, bool, error) {
 	format, stream, err := archiver.Identify(name, bytes.NewReader(data))
 	if err != nil {
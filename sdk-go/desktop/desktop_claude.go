@@ -3,9 +3,7 @@ package desktop
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"path/filepath"
-	"strconv"
 
 	"github.com/llm-infra/secvirt/sdk-go/desktop/claude"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/commands"
@@ -71,13 +69,13 @@ func (s *Sandbox) ClaudeChat(ctx context.Context, content string,
 		o(opt)
 	}
 
-	handle, err := s.Cmd().Start(ctx,
-		fmt.Sprintf("claude -p %s --output-format stream-json --verbose",
-			strconv.Quote(content)),
-		opt.envs,
-		opt.cwd,
-		opt.stdin,
-	)
+	handle, err := s.Cmd().Exec(ctx, commands.Spec{
+		Argv:  []string{"claude", "-p", content, "--output-format", "stream-json", "--verbose"},
+		Login: true,
+		Envs:  opt.envs,
+		Cwd:   opt.cwd,
+		Stdin: opt.stdin,
+	})
 	if err != nil {
 		return nil, err
 	}
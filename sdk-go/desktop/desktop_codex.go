@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
-	"strconv"
 
 	"github.com/BurntSushi/toml"
 	"github.com/llm-infra/acp/sdk/go/acp"
@@ -99,13 +98,13 @@ func (s *Sandbox) CodexChat(ctx context.Context, content string,
 		o(opt)
 	}
 
-	handle, err := s.Cmd().Start(ctx,
-		fmt.Sprintf("codex exec %s --skip-git-repo-check --full-auto --json",
-			strconv.Quote(content)),
-		opt.envs,
-		opt.cwd,
-		opt.stdin,
-	)
+	handle, err := s.Cmd().Exec(ctx, commands.Spec{
+		Argv:  []string{"codex", "exec", content, "--skip-git-repo-check", "--full-auto", "--json"},
+		Login: true,
+		Envs:  opt.envs,
+		Cwd:   opt.cwd,
+		Stdin: opt.stdin,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -120,13 +119,13 @@ func (s *Sandbox) CodexChatWithACPStream(ctx context.Context, content string,
 		o(opt)
 	}
 
-	handle, err := s.Cmd().Start(ctx,
-		fmt.Sprintf("codex exec %s --skip-git-repo-check --full-auto --json",
-			strconv.Quote(content)),
-		opt.envs,
-		opt.cwd,
-		opt.stdin,
-	)
+	handle, err := s.Cmd().Exec(ctx, commands.Spec{
+		Argv:  []string{"codex", "exec", content, "--skip-git-repo-check", "--full-auto", "--json"},
+		Login: true,
+		Envs:  opt.envs,
+		Cwd:   opt.cwd,
+		Stdin: opt.stdin,
+	})
 	if err != nil {
 		return nil, err
 	}
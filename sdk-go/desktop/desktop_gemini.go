@@ -2,9 +2,7 @@ package desktop
 
 import (
 	"context"
-	"fmt"
 	"path/filepath"
-	"strconv"
 
 	"github.com/joho/godotenv"
 	"github.com/llm-infra/acp/sdk/go/acp"
@@ -37,13 +35,13 @@ func (s *Sandbox) GeminiChat(ctx context.Context, content string,
 		o(opt)
 	}
 
-	handle, err := s.Cmd().Start(ctx,
-		fmt.Sprintf("gemini -p %s --output-format stream-json --yolo",
-			strconv.Quote(content)),
-		opt.envs,
-		opt.cwd,
-		opt.stdin,
-	)
+	handle, err := s.Cmd().Exec(ctx, commands.Spec{
+		Argv:  []string{"gemini", "-p", content, "--output-format", "stream-json", "--yolo"},
+		Login: true,
+		Envs:  opt.envs,
+		Cwd:   opt.cwd,
+		Stdin: opt.stdin,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -58,13 +56,13 @@ func (s *Sandbox) GeminiChatWithACPStream(ctx context.Context, content string,
 		o(opt)
 	}
 
-	handle, err := s.Cmd().Start(ctx,
-		fmt.Sprintf("gemini -p %s --output-format stream-json --yolo",
-			strconv.Quote(content)),
-		opt.envs,
-		opt.cwd,
-		opt.stdin,
-	)
+	handle, err := s.Cmd().Exec(ctx, commands.Spec{
+		Argv:  []string{"gemini", "-p", content, "--output-format", "stream-json", "--yolo"},
+		Login: true,
+		Envs:  opt.envs,
+		Cwd:   opt.cwd,
+		Stdin: opt.stdin,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -5,24 +5,24 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/llm-infra/secvirt/sdk-go/desktop/opencode"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/commands"
-	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
 	"github.com/mel2oo/go-dkit/json"
-	"github.com/mel2oo/go-dkit/otel"
 	oc "github.com/sst/opencode-sdk-go"
 	oco "github.com/sst/opencode-sdk-go/option"
 	"github.com/sst/opencode-sdk-go/packages/ssestream"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"mvdan.cc/xurls/v2"
 )
 
+// ocServerTag 标记 opencode serve 进程，SDK 重启后也能找到
+const ocServerTag = "opencode-serve"
+
 type ocServerAttemptError struct {
 	err       error
 	retryable bool
@@ -139,6 +139,11 @@ func (s *Sandbox) RunOcServer(ctx context.Context, port int, opts ...Option) (er
 		o(opt)
 	}
 
+	// 清理之前（包括 SDK 重启前）启动的 opencode serve
+	if err := s.CloseOcServer(); err != nil {
+		return err
+	}
+
 	return runOcServerWithRetry(ctx, opt.ocServerRetries, opt.ocServerRetryWait,
 		func() error {
 			return s.runOcServerOnce(ctx, port, opt)
@@ -187,12 +192,14 @@ func (s *Sandbox) runOcServerOnce(ctx context.Context, port int, opt *Options) e
 
 	s.ocClient = nil
 
-	handle, err := s.Cmd().Start(ctx,
-		fmt.Sprintf("opencode serve --hostname 0.0.0.0 --port %d", port),
-		opt.envs,
-		opt.cwd,
-		opt.stdin,
-	)
+	handle, err := s.Cmd().Exec(ctx, commands.Spec{
+		Command: fmt.Sprintf("opencode serve --hostname 0.0.0.0 --port %d", port),
+		Login:   true,
+		Envs:    opt.envs,
+		Cwd:     opt.cwd,
+		Stdin:   opt.stdin,
+		Tag:     ocServerTag,
+	})
 	if err != nil {
 		return &ocServerAttemptError{err: err, retryable: true}
 	}
@@ -260,37 +267,29 @@ func (s *Sandbox) runOcServerOnce(ctx context.Context, port int, opt *Options) e
 			return err
 		}
 
-		tr := otelhttp.NewTransport(
-			spec.NewHeaderRoundTripper(
-				spec.GenSandboxHeader(port, s.Name, ""),
-				http.DefaultTransport,
-			),
-			otelhttp.WithTracerProvider(otel.Standard().TracerProvider),
-			otelhttp.WithPropagators(otel.Standard().Propagators),
-			otelhttp.WithSpanNameFormatter(otel.HttpSpanNameFormatter),
-		)
-
 		s.ocClient = oc.NewClient(
 			oco.WithBaseURL(s.ProxyBaseURL()),
-			oco.WithHTTPClient(&http.Client{Transport: tr}),
+			oco.WithHTTPClient(s.HTTPClient(port)),
 		)
 		return nil
 	}
 }
 
 func (s *Sandbox) CloseOcServer() error {
-	if s.ocHandle != nil {
-		pid := s.ocHandle.Pid()
-		// 先 SIGTERM 整个进程组，再 SIGKILL 兜底
-		s.Cmd().Run(context.Background(),
-			fmt.Sprintf("kill -TERM -- -%d 2>/dev/null; sleep 1; kill -KILL -- -%d 2>/dev/null", pid, pid),
-			nil, "", false)
-		s.ocHandle = nil
-		s.ocClient = nil
-		return nil
-	}
+	s.ocHandle = nil
 	s.ocClient = nil
-	return nil
+
+	// 先 SIGTERM 整个进程组，再 SIGKILL 兜底
+	ctx := context.Background()
+	err := s.Cmd().Terminate(ctx, commands.ByTag(ocServerTag),
+		time.Second, commands.WithProcessGroup())
+	if err != nil {
+		// 沙箱已停止，没有可清理的 opencode serve；网络错误则不能确定
+		if res, getErr := s.GetSandbox(ctx); getErr == nil && res.State != sandbox.StateRunning {
+			return nil
+		}
+	}
+	return err
 }
 
 func (s *Sandbox) OcClient() *oc.Client {
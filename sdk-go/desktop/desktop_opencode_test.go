@@ -3,10 +3,14 @@ package desktop
 import (
 	"context"
 	"errors"
+	"net/http"
 	"testing"
 	"time"
 
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/sandboxtest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRunOcServerWithRetry_RetriesRetryableErrors(t *testing.T) {
@@ -71,3 +75,29 @@ func TestRunOcServerWithRetry_StopsWhenContextCancelled(t *testing.T) {
 	assert.ErrorIs(t, err, context.Canceled)
 	assert.Equal(t, 1, attempts)
 }
+
+// unreachable fails every request that goes through the sandbox proxy.
+type unreachable struct{}
+
+func (unreachable) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get("X-HOST")) > 0 {
+		return nil, errors.New("connection refused")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestCloseOcServerStoppedSandbox(t *testing.T) {
+	opts := sandboxtest.New(t)
+	sbx, err := NewSandbox(t.Context(), opts...)
+	require.NoError(t, err)
+
+	// Nothing to clean up, RunOcServer goes on.
+	assert.NoError(t, sbx.CloseOcServer())
+	require.NoError(t, sbx.StopSandbox(t.Context()))
+	assert.NoError(t, sbx.CloseOcServer())
+
+	// The server may still run, RunOcServer must not start another one.
+	sbx, err = NewSandbox(t.Context(), append(opts, sandbox.WithTransport(unreachable{}))...)
+	require.NoError(t, err)
+	assert.Error(t, sbx.CloseOcServer())
+}
@@ -3,13 +3,9 @@ package hostmcp
 import (
 	"context"
 	"errors"
-	"net/http"
 
 	"github.com/llm-infra/secvirt/sdk-go/sandbox"
-	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
-	"github.com/mel2oo/go-dkit/otel"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 var (
@@ -33,6 +29,15 @@ func NewSandbox(ctx context.Context, opts ...sandbox.Option) (*Sandbox, error) {
 	return &Sandbox{Sandbox: client}, nil
 }
 
+func Attach(ctx context.Context, id string, opts ...sandbox.Option) (*Sandbox, error) {
+	client, err := sandbox.Attach(ctx, id, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sandbox{Sandbox: client}, nil
+}
+
 func (s *Sandbox) GetLaunchMCPs(ctx context.Context) ([]MCPEndpoint, error) {
 	resp, err := s.ProxyRequest(ctx, DefaultMcpServerPort).
 		SetResult([]MCPEndpoint{}).
@@ -77,20 +82,9 @@ func (s *Sandbox) Launch(ctx context.Context, preloads []Preload,
 
 func (s *Sandbox) Connect(ctx context.Context, endpoint MCPEndpoint,
 ) (*mcp.ClientSession, error) {
-	tr := otelhttp.NewTransport(
-		spec.NewHeaderRoundTripper(
-			spec.GenSandboxHeader(DefaultMcpRouterPort, s.Name, ""),
-			http.DefaultTransport,
-		),
-		otelhttp.WithTracerProvider(otel.Standard().TracerProvider),
-		otelhttp.WithPropagators(otel.Standard().Propagators),
-		otelhttp.WithSpanNameFormatter(otel.HttpSpanNameFormatter),
-	)
-	httpClient := &http.Client{Transport: tr}
-
 	transport := &mcp.StreamableClientTransport{
 		Endpoint:   s.ProxyBaseURL() + endpoint.Path,
-		HTTPClient: httpClient,
+		HTTPClient: s.HTTPClient(DefaultMcpRouterPort),
 	}
 
 	client := mcp.NewClient(&mcp.Implementation{
@@ -0,0 +1,178 @@
+// Package envd implements the process and filesystem connect services that
+// run inside a sandbox. It backs both the envd daemon and the sandboxtest
+// fake backend.
+package envd
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	fsConnect "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem/filesystemconnect"
+	psConnect "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process/processconnect"
+)
+
+const (
+	defaultUser      = "root"
+	defaultKeepAlive = 30 * time.Second
+	defaultPath      = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+type Option func(*Options)
+
+type Options struct {
+	root       string
+	envs       []string
+	switchUser bool
+	keepAlive  time.Duration
+}
+
+// WithRoot maps every sandbox path under dir, e.g. /home/u becomes
+// dir/home/u. Commands still run on the host, only their working directory
+// and HOME are mapped.
+func WithRoot(dir string) Option {
+	return func(o *Options) { o.root = dir }
+}
+
+// WithEnvs sets extra "k=v" environment variables for every process.
+func WithEnvs(envs []string) Option {
+	return func(o *Options) { o.envs = append(o.envs, envs...) }
+}
+
+// WithSwitchUser runs processes as the user of the X-USER header and hands
+// the files it creates to that user. The daemon must run as root.
+func WithSwitchUser() Option {
+	return func(o *Options) { o.switchUser = true }
+}
+
+// WithKeepAlive sets how often idle process and watch streams get a
+// keepalive event, so proxies do not drop them.
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *Options) { o.keepAlive = d }
+}
+
+type Server struct {
+	opt *Options
+
+	procs    *processTable
+	watchers *watcherTable
+}
+
+func New(opts ...Option) *Server {
+	opt := &Options{
+		keepAlive: defaultKeepAlive,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	return &Server{
+		opt:      opt,
+		procs:    newProcessTable(),
+		watchers: newWatcherTable(),
+	}
+}
+
+// Handler serves both connect services.
+func (s *Server) Handler(opts ...connect.HandlerOption) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(psConnect.NewProcessHandler(&processService{s: s}, opts...))
+	mux.Handle(fsConnect.NewFilesystemHandler(&filesystemService{s: s}, opts...))
+	return mux
+}
+
+// Close kills every process that is still running and stops all watchers.
+func (s *Server) Close() {
+	s.procs.killAll()
+	s.watchers.closeAll()
+}
+
+// account is the user a request acts as.
+type account struct {
+	name string
+	home string
+
+	uid, gid   uint32
+	groups     []uint32
+	switchUser bool
+}
+
+// account resolves the user the SDK sent in the X-USER header.
+func (s *Server) account(header http.Header) (*account, error) {
+	name := header.Get("X-USER")
+	if len(name) == 0 {
+		name = defaultUser
+	}
+
+	if !s.opt.switchUser {
+		// Matches sandbox.Sandbox.HomeDir.
+		return &account{name: name, home: "/home/" + name}, nil
+	}
+	return lookupAccount(name)
+}
+
+// env is the base environment of a process started for acc.
+func (s *Server) env(acc *account, home string) []string {
+	var env []string
+	if acc.switchUser {
+		env = []string{"PATH=" + defaultPath, "LOGNAME=" + acc.name}
+	} else {
+		env = os.Environ()
+	}
+
+	env = append(env, s.opt.envs...)
+	return append(env, "HOME="+home, "USER="+acc.name)
+}
+
+// resolve turns a sandbox path into a host path. Relative paths are relative
+// to the user's home directory.
+func (s *Server) resolve(acc *account, path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(acc.home, path)
+	}
+	path = filepath.Clean(path)
+
+	if len(s.opt.root) == 0 {
+		return path
+	}
+	return filepath.Join(s.opt.root, path)
+}
+
+// sandboxPath is the inverse of resolve.
+func (s *Server) sandboxPath(path string) string {
+	if len(s.opt.root) == 0 {
+		return path
+	}
+
+	rel := strings.TrimPrefix(path, filepath.Clean(s.opt.root))
+	if len(rel) == 0 {
+		return "/"
+	}
+	return rel
+}
+
+// mkdirAll is os.MkdirAll that hands every directory it creates to acc.
+func mkdirAll(path string, acc *account) error {
+	info, err := os.Stat(path)
+	if err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: path, Err: errors.New("not a directory")}
+		}
+		return nil
+	}
+
+	if parent := filepath.Dir(path); parent != path {
+		if err := mkdirAll(parent, acc); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(path, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return chown(path, acc)
+}
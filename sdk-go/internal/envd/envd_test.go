@@ -0,0 +1,310 @@
+package envd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem"
+	fsConnect "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem/filesystemconnect"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process"
+	psConnect "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process/processconnect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestServer(t *testing.T, opts ...Option) (psConnect.ProcessClient, fsConnect.FilesystemClient, string) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "home", "root"), 0o755))
+
+	svc := New(append([]Option{WithRoot(root)}, opts...)...)
+	srv := httptest.NewServer(svc.Handler())
+	t.Cleanup(func() {
+		srv.Close()
+		svc.Close()
+	})
+
+	return psConnect.NewProcessClient(http.DefaultClient, srv.URL),
+		fsConnect.NewFilesystemClient(http.DefaultClient, srv.URL),
+		root
+}
+
+func TestPty(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("pty is only supported on linux")
+	}
+	ps, _, _ := newTestServer(t)
+
+	stream, err := ps.Start(t.Context(), connect.NewRequest(&process.StartRequest{
+		Process: &process.ProcessConfig{Cmd: "/bin/sh"},
+		Pty:     &process.PTY{Size: &process.PTY_Size{Cols: 80, Rows: 24}},
+	}))
+	require.NoError(t, err)
+	require.True(t, stream.Receive())
+	pid := stream.Msg().GetEvent().GetStart().GetPid()
+	selector := &process.ProcessSelector{Selector: &process.ProcessSelector_Pid{Pid: pid}}
+
+	_, err = ps.Update(t.Context(), connect.NewRequest(&process.UpdateRequest{
+		Process: selector,
+		Pty:     &process.PTY{Size: &process.PTY_Size{Cols: 100, Rows: 30}},
+	}))
+	require.NoError(t, err)
+
+	_, err = ps.SendInput(t.Context(), connect.NewRequest(&process.SendInputRequest{
+		Process: selector,
+		Input:   &process.ProcessInput{Input: &process.ProcessInput_Pty{Pty: []byte("stty size; exit 7\n")}},
+	}))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	var end *process.ProcessEvent_EndEvent
+	for stream.Receive() {
+		event := stream.Msg().GetEvent()
+		out.Write(event.GetData().GetPty())
+		if event.GetEnd() != nil {
+			end = event.GetEnd()
+		}
+	}
+	require.NoError(t, stream.Err())
+	require.NotNil(t, end)
+	assert.EqualValues(t, 7, end.GetExitCode())
+	assert.Contains(t, out.String(), "30 100")
+}
+
+func TestProcessGroupGone(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process groups are only signaled on linux")
+	}
+
+	leader := exec.Command("sleep", "10")
+	leader.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	require.NoError(t, leader.Start())
+	pid := uint32(leader.Process.Pid)
+	member := exec.Command("sleep", "0.1")
+	member.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: int(pid)}
+	require.NoError(t, member.Start())
+
+	leader.Process.Kill()
+	leader.Wait()
+	procs := newProcessTable()
+	procs.remove(pid)
+	assert.True(t, procs.hasGroup(pid))
+
+	// The group ended on its own: nothing is left to signal.
+	require.NoError(t, member.Wait())
+	assert.False(t, procs.hasGroup(pid))
+	assert.Empty(t, procs.groups)
+}
+
+func TestProcessTagAndKeepAlive(t *testing.T) {
+	ps, _, _ := newTestServer(t, WithKeepAlive(20*time.Millisecond))
+
+	tag := "server"
+	stream, err := ps.Start(t.Context(), connect.NewRequest(&process.StartRequest{
+		Process: &process.ProcessConfig{Cmd: "sleep", Args: []string{"0.2"}},
+		Tag:     &tag,
+	}))
+	require.NoError(t, err)
+	require.True(t, stream.Receive())
+
+	dup, err := ps.Start(t.Context(), connect.NewRequest(&process.StartRequest{
+		Process: &process.ProcessConfig{Cmd: "true"},
+		Tag:     &tag,
+	}))
+	require.NoError(t, err)
+	assert.False(t, dup.Receive())
+	assert.Equal(t, connect.CodeAlreadyExists, connect.CodeOf(dup.Err()))
+
+	conn, err := ps.Connect(t.Context(), connect.NewRequest(&process.ConnectRequest{
+		Process: &process.ProcessSelector{Selector: &process.ProcessSelector_Tag{Tag: tag}},
+	}))
+	require.NoError(t, err)
+
+	var keepAlives int
+	var ended bool
+	for conn.Receive() {
+		event := conn.Msg().GetEvent()
+		if event.GetKeepalive() != nil {
+			keepAlives++
+		}
+		if event.GetEnd() != nil {
+			ended = true
+		}
+	}
+	require.NoError(t, conn.Err())
+	assert.True(t, ended)
+	assert.Positive(t, keepAlives)
+}
+
+func TestProcessTagClaim(t *testing.T) {
+	ps, _, _ := newTestServer(t)
+
+	tag := "server"
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	for range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stream, err := ps.Start(t.Context(), connect.NewRequest(&process.StartRequest{
+				Process: &process.ProcessConfig{Cmd: "sleep", Args: []string{"0.5"}},
+				Tag:     &tag,
+			}))
+			if err == nil && stream.Receive() {
+				started.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), started.Load())
+}
+
+func TestCloseKillsGroups(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("process groups are only signaled on linux")
+	}
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "home", "root"), 0o755))
+	svc := New(WithRoot(root))
+	srv := httptest.NewServer(svc.Handler())
+	defer srv.Close()
+	ps := psConnect.NewProcessClient(http.DefaultClient, srv.URL)
+
+	stream, err := ps.Start(t.Context(), connect.NewRequest(&process.StartRequest{
+		Process: &process.ProcessConfig{Cmd: "sh", Args: []string{"-c", "sleep 10 & echo $!; wait"}},
+	}))
+	require.NoError(t, err)
+	var out []byte
+	for len(out) == 0 && stream.Receive() {
+		out = stream.Msg().GetEvent().GetData().GetStdout()
+	}
+	child := strings.TrimSpace(string(out))
+	require.NotEmpty(t, child)
+
+	svc.Close()
+	// Killed, if not reaped: the child may be left a zombie.
+	require.Eventually(t, func() bool {
+		stat, err := os.ReadFile(filepath.Join("/proc", child, "stat"))
+		return err != nil || strings.Contains(string(stat), ") Z ")
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWatcher(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("watching is only supported on linux")
+	}
+	_, fs, root := newTestServer(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "w"), 0o755))
+
+	stream, err := fs.WatchDir(t.Context(), connect.NewRequest(&filesystem.WatchDirRequest{
+		Path:      "/w",
+		Recursive: true,
+	}))
+	require.NoError(t, err)
+	require.True(t, stream.Receive())
+	require.NotNil(t, stream.Msg().GetStart())
+
+	created, err := fs.CreateWatcher(t.Context(), connect.NewRequest(&filesystem.CreateWatcherRequest{
+		Path: "/w",
+	}))
+	require.NoError(t, err)
+	id := created.Msg.GetWatcherId()
+
+	require.NoError(t, os.Mkdir(filepath.Join(root, "w", "sub"), 0o755))
+	// Give the recursive watch time to pick up the new directory.
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "w", "sub", "f"), []byte("x"), 0o644))
+
+	seen := make(map[string]filesystem.EventType)
+	for len(seen) < 2 && stream.Receive() {
+		if event := stream.Msg().GetFilesystem(); event != nil {
+			if _, ok := seen[event.GetName()]; !ok {
+				seen[event.GetName()] = event.GetType()
+			}
+		}
+	}
+	assert.Equal(t, filesystem.EventType_EVENT_TYPE_CREATE, seen["sub"])
+	assert.Equal(t, filesystem.EventType_EVENT_TYPE_CREATE, seen["sub/f"])
+
+	events, err := fs.GetWatcherEvents(t.Context(), connect.NewRequest(&filesystem.GetWatcherEventsRequest{
+		WatcherId: id,
+	}))
+	require.NoError(t, err)
+	require.NotEmpty(t, events.Msg.GetEvents())
+	assert.Equal(t, "sub", events.Msg.GetEvents()[0].GetName())
+
+	_, err = fs.RemoveWatcher(t.Context(), connect.NewRequest(&filesystem.RemoveWatcherRequest{WatcherId: id}))
+	require.NoError(t, err)
+	_, err = fs.GetWatcherEvents(t.Context(), connect.NewRequest(&filesystem.GetWatcherEventsRequest{
+		WatcherId: id,
+	}))
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+}
+
+func TestStreamInputEnd(t *testing.T) {
+	ps, _, _ := newTestServer(t)
+
+	stream, err := ps.Start(t.Context(), connect.NewRequest(&process.StartRequest{
+		Process: &process.ProcessConfig{Cmd: "cat"},
+		Stdin:   proto.Bool(true),
+	}))
+	require.NoError(t, err)
+	require.True(t, stream.Receive())
+	pid := stream.Msg().GetEvent().GetStart().GetPid()
+	selector := &process.ProcessSelector{Selector: &process.ProcessSelector_Pid{Pid: pid}}
+
+	send := func(events ...*process.StreamInputRequest) {
+		input := ps.StreamInput(t.Context())
+		require.NoError(t, input.Send(&process.StreamInputRequest{
+			Event: &process.StreamInputRequest_Start{
+				Start: &process.StreamInputRequest_StartEvent{Process: selector},
+			},
+		}))
+		for _, event := range events {
+			require.NoError(t, input.Send(event))
+		}
+		_, err := input.CloseAndReceive()
+		require.NoError(t, err)
+	}
+	data := func(s string) *process.StreamInputRequest {
+		return &process.StreamInputRequest{
+			Event: &process.StreamInputRequest_Data{
+				Data: &process.StreamInputRequest_DataEvent{
+					Input: &process.ProcessInput{Input: &process.ProcessInput_Stdin{Stdin: []byte(s)}},
+				},
+			},
+		}
+	}
+
+	// Ending a call without the end event leaves stdin open.
+	send(data("a"))
+	send(data("b"), &process.StreamInputRequest{
+		Event: &process.StreamInputRequest_End{End: &process.StreamInputRequest_EndEvent{}},
+	})
+
+	var out bytes.Buffer
+	var end *process.ProcessEvent_EndEvent
+	for stream.Receive() {
+		event := stream.Msg().GetEvent()
+		out.Write(event.GetData().GetStdout())
+		if event.GetEnd() != nil {
+			end = event.GetEnd()
+		}
+	}
+	require.NoError(t, stream.Err())
+	require.NotNil(t, end)
+	assert.EqualValues(t, 0, end.GetExitCode())
+	assert.Equal(t, "ab", out.String())
+}
@@ -0,0 +1,312 @@
+package envd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"connectrpc.com/connect"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem"
+	fsConnect "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem/filesystemconnect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const readChunkSize = 64 * 1024
+
+type filesystemService struct {
+	fsConnect.UnimplementedFilesystemHandler
+
+	s *Server
+}
+
+// fsError maps an os error to the connect code the SDK expects.
+func fsError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return connect.NewError(connect.CodeNotFound, err)
+	case errors.Is(err, fs.ErrExist):
+		return connect.NewError(connect.CodeAlreadyExists, err)
+	case errors.Is(err, fs.ErrPermission):
+		return connect.NewError(connect.CodePermissionDenied, err)
+	}
+	return connect.NewError(connect.CodeInternal, err)
+}
+
+// path resolves a request path for the X-USER account.
+func (f *filesystemService) path(header http.Header, path string) (string, *account, error) {
+	acc, err := f.s.account(header)
+	if err != nil {
+		return "", nil, err
+	}
+	return f.s.resolve(acc, path), acc, nil
+}
+
+func (f *filesystemService) entry(path string) (*filesystem.EntryInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &filesystem.EntryInfo{
+		Name:         info.Name(),
+		Path:         f.s.sandboxPath(path),
+		Size:         info.Size(),
+		Mode:         uint32(info.Mode().Perm()),
+		Permissions:  info.Mode().String(),
+		ModifiedTime: timestamppb.New(info.ModTime()),
+	}
+	entry.Owner, entry.Group = fileOwner(info)
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err == nil {
+			entry.SymlinkTarget = &target
+		}
+		if stat, err := os.Stat(path); err == nil {
+			info = stat
+		}
+	}
+
+	if info.IsDir() {
+		entry.Type = filesystem.FileType_FILE_TYPE_DIRECTORY
+	} else {
+		entry.Type = filesystem.FileType_FILE_TYPE_FILE
+	}
+	return entry, nil
+}
+
+func (f *filesystemService) Read(
+	ctx context.Context,
+	req *connect.Request[filesystem.ReadRequest],
+	stream *connect.ServerStream[filesystem.ReadResponse],
+) error {
+	path, _, err := f.path(req.Header(), req.Msg.GetPath())
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fsError(err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fsError(err)
+	}
+	if info.IsDir() {
+		return connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("%s is a directory", req.Msg.GetPath()))
+	}
+
+	buf := make([]byte, readChunkSize)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&filesystem.ReadResponse{Chunk: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fsError(err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (f *filesystemService) Write(
+	ctx context.Context,
+	stream *connect.ClientStream[filesystem.WriteRequest],
+) (*connect.Response[filesystem.WriteResponse], error) {
+	var file *os.File
+	for stream.Receive() {
+		msg := stream.Msg()
+		if file == nil {
+			path, acc, err := f.path(stream.RequestHeader(), msg.GetPath())
+			if err != nil {
+				return nil, err
+			}
+			if err := mkdirAll(filepath.Dir(path), acc); err != nil {
+				return nil, fsError(err)
+			}
+
+			file, err = os.Create(path)
+			if err != nil {
+				return nil, fsError(err)
+			}
+			defer file.Close()
+			if err := chown(path, acc); err != nil {
+				return nil, fsError(err)
+			}
+		}
+
+		if _, err := file.Write(msg.GetChunk()); err != nil {
+			return nil, fsError(err)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty write"))
+	}
+	if err := file.Close(); err != nil {
+		return nil, fsError(err)
+	}
+
+	entry, err := f.entry(file.Name())
+	if err != nil {
+		return nil, fsError(err)
+	}
+	return connect.NewResponse(&filesystem.WriteResponse{Entry: entry}), nil
+}
+
+func (f *filesystemService) Stat(
+	ctx context.Context,
+	req *connect.Request[filesystem.StatRequest],
+) (*connect.Response[filesystem.StatResponse], error) {
+	path, _, err := f.path(req.Header(), req.Msg.GetPath())
+	if err != nil {
+		return nil, err
+	}
+
+	entry, err := f.entry(path)
+	if err != nil {
+		return nil, fsError(err)
+	}
+	return connect.NewResponse(&filesystem.StatResponse{Entry: entry}), nil
+}
+
+func (f *filesystemService) Move(
+	ctx context.Context,
+	req *connect.Request[filesystem.MoveRequest],
+) (*connect.Response[filesystem.MoveResponse], error) {
+	src, acc, err := f.path(req.Header(), req.Msg.GetSource())
+	if err != nil {
+		return nil, err
+	}
+	dst := f.s.resolve(acc, req.Msg.GetDestination())
+
+	if err := mkdirAll(filepath.Dir(dst), acc); err != nil {
+		return nil, fsError(err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return nil, fsError(err)
+	}
+
+	entry, err := f.entry(dst)
+	if err != nil {
+		return nil, fsError(err)
+	}
+	return connect.NewResponse(&filesystem.MoveResponse{Entry: entry}), nil
+}
+
+func (f *filesystemService) Remove(
+	ctx context.Context,
+	req *connect.Request[filesystem.RemoveRequest],
+) (*connect.Response[filesystem.RemoveResponse], error) {
+	path, _, err := f.path(req.Header(), req.Msg.GetPath())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Lstat(path); err != nil {
+		return nil, fsError(err)
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return nil, fsError(err)
+	}
+	return connect.NewResponse(&filesystem.RemoveResponse{}), nil
+}
+
+func (f *filesystemService) MakeDir(
+	ctx context.Context,
+	req *connect.Request[filesystem.MakeDirRequest],
+) (*connect.Response[filesystem.MakeDirResponse], error) {
+	path, acc, err := f.path(req.Header(), req.Msg.GetPath())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := os.Lstat(path); err == nil {
+		return nil, fsError(fmt.Errorf("%s: %w", req.Msg.GetPath(), fs.ErrExist))
+	}
+	if err := mkdirAll(path, acc); err != nil {
+		return nil, fsError(err)
+	}
+
+	entry, err := f.entry(path)
+	if err != nil {
+		return nil, fsError(err)
+	}
+	return connect.NewResponse(&filesystem.MakeDirResponse{Entry: entry}), nil
+}
+
+// ListDir lists path down to depth levels, 0 is the same as 1: only its
+// direct entries.
+func (f *filesystemService) ListDir(
+	ctx context.Context,
+	req *connect.Request[filesystem.ListDirRequest],
+) (*connect.Response[filesystem.ListDirResponse], error) {
+	root, _, err := f.path(req.Header(), req.Msg.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	depth := max(int(req.Msg.GetDepth()), 1)
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fsError(err)
+	}
+	if !info.IsDir() {
+		return nil, connect.NewError(connect.CodeInvalidArgument,
+			fmt.Errorf("%s is not a directory", req.Msg.GetPath()))
+	}
+
+	entries := make([]*filesystem.EntryInfo, 0)
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		entry, err := f.entry(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+
+		rel, _ := filepath.Rel(root, path)
+		if d.IsDir() && countSegments(rel) >= depth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fsError(err)
+	}
+	return connect.NewResponse(&filesystem.ListDirResponse{Entries: entries}), nil
+}
+
+func countSegments(rel string) int {
+	n := 1
+	for _, c := range rel {
+		if c == filepath.Separator {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package envd
+
+import "io/fs"
+
+func fileOwner(info fs.FileInfo) (string, string) {
+	return "", ""
+}
@@ -0,0 +1,634 @@
+package envd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process"
+	psConnect "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process/processconnect"
+)
+
+// outputWaitDelay bounds how long output is still collected after the process
+// exited, e.g. from a background child holding stdout open.
+const outputWaitDelay = time.Second
+
+type processTable struct {
+	mu    sync.Mutex
+	procs map[uint32]*proc
+	// Tags of the running processes and of those being started.
+	tags map[string]struct{}
+	// Groups whose leader exited while other members still ran.
+	groups map[uint32]struct{}
+}
+
+func newProcessTable() *processTable {
+	return &processTable{
+		procs:  make(map[uint32]*proc),
+		tags:   make(map[string]struct{}),
+		groups: make(map[uint32]struct{}),
+	}
+}
+
+func (t *processTable) add(p *proc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.procs[p.pid] = p
+}
+
+func (t *processTable) remove(pid uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.procs[pid]; ok && p.tag != nil {
+		delete(t.tags, *p.tag)
+	}
+	delete(t.procs, pid)
+
+	// Keep the group signalable while the leader's children still run.
+	if signalGroup(int(pid), 0) == nil {
+		t.groups[pid] = struct{}{}
+	}
+	for pgid := range t.groups {
+		if signalGroup(int(pgid), 0) != nil {
+			delete(t.groups, pgid)
+		}
+	}
+}
+
+// hasGroup reports whether pid led a group that outlived it and still runs.
+// While a group has members its id is not reused, so it can be signaled.
+func (t *processTable) hasGroup(pid uint32) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.groups[pid]; !ok {
+		return false
+	}
+	if signalGroup(int(pid), 0) != nil {
+		delete(t.groups, pid)
+		return false
+	}
+	return true
+}
+
+func (t *processTable) removeGroup(pid uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.groups, pid)
+}
+
+func (t *processTable) get(selector *process.ProcessSelector) (*proc, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch s := selector.GetSelector().(type) {
+	case *process.ProcessSelector_Pid:
+		if p, ok := t.procs[s.Pid]; ok {
+			return p, nil
+		}
+		return nil, connect.NewError(connect.CodeNotFound,
+			fmt.Errorf("process %d not found", s.Pid))
+	case *process.ProcessSelector_Tag:
+		for _, p := range t.procs {
+			if p.tag != nil && *p.tag == s.Tag {
+				return p, nil
+			}
+		}
+		return nil, connect.NewError(connect.CodeNotFound,
+			fmt.Errorf("process with tag %s not found", s.Tag))
+	}
+	return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("empty process selector"))
+}
+
+// claimTag reserves tag for a process about to start. It fails when a
+// running or starting process has it.
+func (t *processTable) claimTag(tag string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.tags[tag]; ok {
+		return false
+	}
+	t.tags[tag] = struct{}{}
+	return true
+}
+
+func (t *processTable) releaseTag(tag string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tags, tag)
+}
+
+func (t *processTable) list() []*proc {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	procs := make([]*proc, 0, len(t.procs))
+	for _, p := range t.procs {
+		procs = append(procs, p)
+	}
+	return procs
+}
+
+// killAll kills the process group of every process, and the groups that
+// outlived their leader.
+func (t *processTable) killAll() {
+	t.mu.Lock()
+	procs := make([]*proc, 0, len(t.procs))
+	for _, p := range t.procs {
+		procs = append(procs, p)
+	}
+	pgids := make([]uint32, 0, len(t.groups))
+	for pgid := range t.groups {
+		pgids = append(pgids, pgid)
+	}
+	t.mu.Unlock()
+
+	for _, p := range procs {
+		if signalGroup(int(p.pid), syscall.SIGKILL) != nil {
+			p.cmd.Process.Kill()
+		}
+	}
+	for _, pgid := range pgids {
+		signalGroup(int(pgid), syscall.SIGKILL)
+	}
+}
+
+// proc is a started process. Its output is fanned out to every stream
+// subscribed to it, the Start stream and any Connect streams.
+type proc struct {
+	pid    uint32
+	tag    *string
+	config *process.ProcessConfig
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	pty    *os.File
+
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+	done chan struct{}
+	end  *process.ProcessEvent_EndEvent
+}
+
+type subscriber struct {
+	events chan *process.ProcessEvent
+	gone   chan struct{}
+}
+
+func (p *proc) subscribe() *subscriber {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sub := &subscriber{
+		events: make(chan *process.ProcessEvent, 64),
+		gone:   make(chan struct{}),
+	}
+	p.subs[sub] = struct{}{}
+	return sub
+}
+
+func (p *proc) unsubscribe(sub *subscriber) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.subs[sub]; ok {
+		delete(p.subs, sub)
+		close(sub.gone)
+	}
+}
+
+func (p *proc) broadcast(event *process.ProcessEvent) {
+	p.mu.Lock()
+	subs := make([]*subscriber, 0, len(p.subs))
+	for sub := range p.subs {
+		subs = append(subs, sub)
+	}
+	p.mu.Unlock()
+
+	for _, sub := range subs {
+		select {
+		case sub.events <- event:
+		case <-sub.gone:
+		}
+	}
+}
+
+// stream forwards the events of p to send until the process ended or ctx is
+// done. The end event is always the last one; keepalive events fill the gaps.
+func (p *proc) stream(
+	ctx context.Context,
+	sub *subscriber,
+	keepAlive time.Duration,
+	send func(*process.ProcessEvent) error,
+) error {
+	defer p.unsubscribe(sub)
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := send(&process.ProcessEvent{
+				Event: &process.ProcessEvent_Keepalive{Keepalive: &process.ProcessEvent_KeepAlive{}},
+			}); err != nil {
+				return err
+			}
+		case event := <-sub.events:
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-p.done:
+			for {
+				select {
+				case event := <-sub.events:
+					if err := send(event); err != nil {
+						return err
+					}
+				default:
+					return send(&process.ProcessEvent{
+						Event: &process.ProcessEvent_End{End: p.end},
+					})
+				}
+			}
+		}
+	}
+}
+
+// outputWriter turns writes to stdout or stderr into data events.
+type outputWriter struct {
+	p      *proc
+	stderr bool
+}
+
+func (w *outputWriter) Write(b []byte) (int, error) {
+	data := &process.ProcessEvent_DataEvent{}
+	chunk := append([]byte(nil), b...)
+	if w.stderr {
+		data.Output = &process.ProcessEvent_DataEvent_Stderr{Stderr: chunk}
+	} else {
+		data.Output = &process.ProcessEvent_DataEvent_Stdout{Stdout: chunk}
+	}
+
+	w.p.broadcast(&process.ProcessEvent{
+		Event: &process.ProcessEvent_Data{Data: data},
+	})
+	return len(b), nil
+}
+
+func (p *proc) readPty(done chan struct{}) {
+	defer close(done)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := p.pty.Read(buf)
+		if n > 0 {
+			p.broadcast(&process.ProcessEvent{
+				Event: &process.ProcessEvent_Data{Data: &process.ProcessEvent_DataEvent{
+					Output: &process.ProcessEvent_DataEvent_Pty{Pty: append([]byte(nil), buf[:n]...)},
+				}},
+			})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+type processService struct {
+	psConnect.UnimplementedProcessHandler
+
+	s *Server
+}
+
+func (ps *processService) start(acc *account, req *process.StartRequest) (*proc, *subscriber, error) {
+	tag := req.Tag
+	if tag != nil && !ps.s.procs.claimTag(*tag) {
+		return nil, nil, connect.NewError(connect.CodeAlreadyExists,
+			fmt.Errorf("process with tag %s already running", *tag))
+	}
+
+	p, sub, err := ps.spawn(acc, req)
+	// Once started, remove releases the tag.
+	if err != nil && tag != nil {
+		ps.s.procs.releaseTag(*tag)
+	}
+	return p, sub, err
+}
+
+func (ps *processService) spawn(acc *account, req *process.StartRequest) (*proc, *subscriber, error) {
+
+	config := req.GetProcess()
+	cmd := exec.Command(config.GetCmd(), config.GetArgs()...)
+
+	home := ps.s.resolve(acc, acc.home)
+	cmd.Dir = home
+	if cwd := config.GetCwd(); len(cwd) > 0 {
+		cmd.Dir = ps.s.resolve(acc, cwd)
+	}
+
+	cmd.Env = ps.s.env(acc, home)
+	for k, v := range config.GetEnvs() {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.SysProcAttr = sysProcAttr(acc, req.Pty != nil)
+
+	p := &proc{
+		tag:    req.Tag,
+		config: config,
+		cmd:    cmd,
+		subs:   make(map[*subscriber]struct{}),
+		done:   make(chan struct{}),
+	}
+
+	var slave *os.File
+	if req.Pty != nil {
+		size := req.GetPty().GetSize()
+		master, tty, err := openPty(size.GetCols(), size.GetRows())
+		if err != nil {
+			return nil, nil, connect.NewError(connect.CodeInternal, err)
+		}
+		p.pty, slave = master, tty
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	} else {
+		cmd.Stdout = &outputWriter{p: p}
+		cmd.Stderr = &outputWriter{p: p, stderr: true}
+		cmd.WaitDelay = outputWaitDelay
+
+		if req.GetStdin() {
+			stdin, err := cmd.StdinPipe()
+			if err != nil {
+				return nil, nil, connect.NewError(connect.CodeInternal, err)
+			}
+			p.stdin = stdin
+		}
+	}
+
+	// Subscribe before starting so no output is missed.
+	sub := p.subscribe()
+	err := cmd.Start()
+	if slave != nil {
+		slave.Close()
+	}
+	if err != nil {
+		if p.pty != nil {
+			p.pty.Close()
+		}
+		return nil, nil, connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	p.pid = uint32(cmd.Process.Pid)
+	ps.s.procs.add(p)
+
+	ptyDone := make(chan struct{})
+	if p.pty != nil {
+		go p.readPty(ptyDone)
+	} else {
+		close(ptyDone)
+	}
+
+	go func() {
+		err := cmd.Wait()
+
+		// The pty keeps its buffered output after the process exited.
+		select {
+		case <-ptyDone:
+		case <-time.After(outputWaitDelay):
+		}
+		if p.pty != nil {
+			p.pty.Close()
+		}
+
+		end := &process.ProcessEvent_EndEvent{
+			ExitCode: int32(cmd.ProcessState.ExitCode()),
+			Exited:   cmd.ProcessState.Exited(),
+			Status:   cmd.ProcessState.String(),
+		}
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay) {
+			msg := err.Error()
+			end.Error = &msg
+		}
+
+		ps.s.procs.remove(p.pid)
+		p.end = end
+		close(p.done)
+	}()
+
+	return p, sub, nil
+}
+
+func (ps *processService) Start(
+	ctx context.Context,
+	req *connect.Request[process.StartRequest],
+	stream *connect.ServerStream[process.StartResponse],
+) error {
+	acc, err := ps.s.account(req.Header())
+	if err != nil {
+		return err
+	}
+	p, sub, err := ps.start(acc, req.Msg)
+	if err != nil {
+		return err
+	}
+
+	send := func(event *process.ProcessEvent) error {
+		return stream.Send(&process.StartResponse{Event: event})
+	}
+	if err := send(startEvent(p.pid)); err != nil {
+		p.unsubscribe(sub)
+		return err
+	}
+	return p.stream(ctx, sub, ps.s.opt.keepAlive, send)
+}
+
+func (ps *processService) Connect(
+	ctx context.Context,
+	req *connect.Request[process.ConnectRequest],
+	stream *connect.ServerStream[process.ConnectResponse],
+) error {
+	p, err := ps.s.procs.get(req.Msg.GetProcess())
+	if err != nil {
+		return err
+	}
+	sub := p.subscribe()
+
+	send := func(event *process.ProcessEvent) error {
+		return stream.Send(&process.ConnectResponse{Event: event})
+	}
+	if err := send(startEvent(p.pid)); err != nil {
+		p.unsubscribe(sub)
+		return err
+	}
+	return p.stream(ctx, sub, ps.s.opt.keepAlive, send)
+}
+
+func startEvent(pid uint32) *process.ProcessEvent {
+	return &process.ProcessEvent{
+		Event: &process.ProcessEvent_Start{
+			Start: &process.ProcessEvent_StartEvent{Pid: pid},
+		},
+	}
+}
+
+func (ps *processService) List(
+	ctx context.Context,
+	req *connect.Request[process.ListRequest],
+) (*connect.Response[process.ListResponse], error) {
+	infos := make([]*process.ProcessInfo, 0)
+	for _, p := range ps.s.procs.list() {
+		infos = append(infos, &process.ProcessInfo{
+			Config: p.config,
+			Pid:    p.pid,
+			Tag:    p.tag,
+		})
+	}
+	return connect.NewResponse(&process.ListResponse{Processes: infos}), nil
+}
+
+func (ps *processService) write(p *proc, input *process.ProcessInput) error {
+	switch in := input.GetInput().(type) {
+	case *process.ProcessInput_Stdin:
+		if p.stdin == nil {
+			return connect.NewError(connect.CodeFailedPrecondition,
+				fmt.Errorf("process %d has no stdin", p.pid))
+		}
+		if _, err := p.stdin.Write(in.Stdin); err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return connect.NewError(connect.CodeFailedPrecondition,
+					fmt.Errorf("stdin of process %d is closed", p.pid))
+			}
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		return nil
+	case *process.ProcessInput_Pty:
+		if p.pty == nil {
+			return connect.NewError(connect.CodeFailedPrecondition,
+				fmt.Errorf("process %d has no pty", p.pid))
+		}
+		if _, err := p.pty.Write(in.Pty); err != nil {
+			return connect.NewError(connect.CodeInternal, err)
+		}
+		return nil
+	}
+	return connect.NewError(connect.CodeInvalidArgument, errors.New("empty process input"))
+}
+
+func (ps *processService) SendInput(
+	ctx context.Context,
+	req *connect.Request[process.SendInputRequest],
+) (*connect.Response[process.SendInputResponse], error) {
+	p, err := ps.s.procs.get(req.Msg.GetProcess())
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.write(p, req.Msg.GetInput()); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&process.SendInputResponse{}), nil
+}
+
+func (ps *processService) StreamInput(
+	ctx context.Context,
+	stream *connect.ClientStream[process.StreamInputRequest],
+) (*connect.Response[process.StreamInputResponse], error) {
+	var p *proc
+	for stream.Receive() {
+		switch event := stream.Msg().GetEvent().(type) {
+		case *process.StreamInputRequest_Start:
+			var err error
+			if p, err = ps.s.procs.get(event.Start.GetProcess()); err != nil {
+				return nil, err
+			}
+		case *process.StreamInputRequest_Data:
+			if p == nil {
+				return nil, connect.NewError(connect.CodeFailedPrecondition,
+					errors.New("input before start event"))
+			}
+			if err := ps.write(p, event.Data.GetInput()); err != nil {
+				return nil, err
+			}
+		case *process.StreamInputRequest_End:
+			if p == nil {
+				return nil, connect.NewError(connect.CodeFailedPrecondition,
+					errors.New("end before start event"))
+			}
+			if p.stdin != nil {
+				p.stdin.Close()
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&process.StreamInputResponse{}), nil
+}
+
+func (ps *processService) Update(
+	ctx context.Context,
+	req *connect.Request[process.UpdateRequest],
+) (*connect.Response[process.UpdateResponse], error) {
+	p, err := ps.s.procs.get(req.Msg.GetProcess())
+	if err != nil {
+		return nil, err
+	}
+
+	if size := req.Msg.GetPty().GetSize(); size != nil {
+		if p.pty == nil {
+			return nil, connect.NewError(connect.CodeFailedPrecondition,
+				fmt.Errorf("process %d has no pty", p.pid))
+		}
+		if err := resizePty(p.pty, size.GetCols(), size.GetRows()); err != nil {
+			return nil, connect.NewError(connect.CodeInternal, err)
+		}
+	}
+	return connect.NewResponse(&process.UpdateResponse{}), nil
+}
+
+func (ps *processService) SendSignal(
+	ctx context.Context,
+	req *connect.Request[process.SendSignalRequest],
+) (*connect.Response[process.SendSignalResponse], error) {
+	sig := syscall.Signal(req.Msg.GetSignal())
+	if sig == 0 {
+		return nil, connect.NewError(connect.CodeInvalidArgument, errors.New("signal is not set"))
+	}
+
+	group := req.Msg.GetGroup()
+	pid := req.Msg.GetProcess().GetPid()
+	p, err := ps.s.procs.get(req.Msg.GetProcess())
+	switch {
+	case err == nil:
+		pid = p.pid
+	case group && ps.s.procs.hasGroup(pid):
+		// The leader exited, the rest of its group still runs.
+	default:
+		return nil, err
+	}
+
+	if group {
+		err = signalGroup(int(pid), sig)
+		if errors.Is(err, os.ErrProcessDone) {
+			ps.s.procs.removeGroup(pid)
+		}
+	} else {
+		err = p.cmd.Process.Signal(sig)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrProcessDone):
+			return nil, connect.NewError(connect.CodeNotFound, err)
+		case errors.Is(err, errors.ErrUnsupported):
+			return nil, connect.NewError(connect.CodeUnimplemented, err)
+		}
+		return nil, connect.NewError(connect.CodeInternal, err)
+	}
+	return connect.NewResponse(&process.SendSignalResponse{}), nil
+}
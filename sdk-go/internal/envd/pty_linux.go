@@ -0,0 +1,60 @@
+//go:build linux
+
+package envd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+)
+
+// openPty opens a pseudo terminal pair of the given size.
+func openPty(cols, rows uint32) (*os.File, *os.File, error) {
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return nil, nil, fmt.Errorf("open pty: %w", err)
+	}
+	if err := pty.Setsize(ptmx, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)}); err != nil {
+		ptmx.Close()
+		tty.Close()
+		return nil, nil, fmt.Errorf("resize pty: %w", err)
+	}
+
+	// pty leaves the master blocking. A non-blocking copy goes through the
+	// poller, so Close interrupts reads.
+	// The copy is close-on-exec from the start, so a concurrent fork cannot
+	// inherit it.
+	fd, err := unix.FcntlInt(ptmx.Fd(), unix.F_DUPFD_CLOEXEC, 0)
+	ptmx.Close()
+	if err != nil {
+		tty.Close()
+		return nil, nil, fmt.Errorf("dup pty: %w", err)
+	}
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		tty.Close()
+		return nil, nil, fmt.Errorf("dup pty: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "/dev/ptmx"), tty, nil
+}
+
+func resizePty(master *os.File, cols, rows uint32) error {
+	conn, err := master.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		ioctlErr = unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, &unix.Winsize{
+			Col: uint16(cols),
+			Row: uint16(rows),
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return ioctlErr
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package envd
+
+import (
+	"errors"
+	"os"
+)
+
+var errPtyUnsupported = errors.New("pty is only supported on linux")
+
+func openPty(cols, rows uint32) (*os.File, *os.File, error) {
+	return nil, nil, errPtyUnsupported
+}
+
+func resizePty(master *os.File, cols, rows uint32) error {
+	return errPtyUnsupported
+}
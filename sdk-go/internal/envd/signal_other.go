@@ -0,0 +1,12 @@
+//go:build !unix
+
+package envd
+
+import (
+	"errors"
+	"syscall"
+)
+
+func signalGroup(pid int, sig syscall.Signal) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package envd
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// signalGroup sends sig to the process group led by pid. Every process is
+// started in its own group, see sysProcAttr.
+func signalGroup(pid int, sig syscall.Signal) error {
+	err := syscall.Kill(-pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		return os.ErrProcessDone
+	}
+	return err
+}
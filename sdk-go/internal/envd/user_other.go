@@ -0,0 +1,22 @@
+//go:build !unix
+
+package envd
+
+import (
+	"errors"
+	"syscall"
+
+	"connectrpc.com/connect"
+)
+
+func lookupAccount(name string) (*account, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("switching user is not supported"))
+}
+
+func sysProcAttr(acc *account, pty bool) *syscall.SysProcAttr {
+	return nil
+}
+
+func chown(path string, acc *account) error {
+	return nil
+}
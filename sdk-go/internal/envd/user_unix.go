@@ -0,0 +1,67 @@
+//go:build unix
+
+package envd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"connectrpc.com/connect"
+)
+
+func lookupAccount(name string) (*account, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, fmt.Errorf("unknown user %s: %w", name, err))
+	}
+
+	uid, _ := strconv.ParseUint(u.Uid, 10, 32)
+	gid, _ := strconv.ParseUint(u.Gid, 10, 32)
+	acc := &account{
+		name:       name,
+		home:       u.HomeDir,
+		uid:        uint32(uid),
+		gid:        uint32(gid),
+		switchUser: true,
+	}
+
+	groups, _ := u.GroupIds()
+	for _, g := range groups {
+		if id, err := strconv.ParseUint(g, 10, 32); err == nil {
+			acc.groups = append(acc.groups, uint32(id))
+		}
+	}
+	return acc, nil
+}
+
+// sysProcAttr puts a process in its own process group, or its own session
+// with the pty as controlling terminal, and runs it as the account.
+func sysProcAttr(acc *account, pty bool) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{}
+	if pty {
+		attr.Setsid = true
+		attr.Setctty = true
+	} else {
+		attr.Setpgid = true
+	}
+
+	if acc.switchUser {
+		attr.Credential = &syscall.Credential{
+			Uid:    acc.uid,
+			Gid:    acc.gid,
+			Groups: acc.groups,
+		}
+	}
+	return attr
+}
+
+// chown hands a file created by the daemon to the account.
+func chown(path string, acc *account) error {
+	if !acc.switchUser {
+		return nil
+	}
+	return os.Lchown(path, int(acc.uid), int(acc.gid))
+}
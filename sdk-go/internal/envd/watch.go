@@ -0,0 +1,193 @@
+package envd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/google/uuid"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem"
+)
+
+// maxWatcherEvents bounds the events a polled watcher buffers between two
+// GetWatcherEvents calls; older events are dropped first.
+const maxWatcherEvents = 4096
+
+// pollWatcher buffers the events of a watcher created by CreateWatcher.
+type pollWatcher struct {
+	w *dirWatcher
+
+	mu     sync.Mutex
+	events []*filesystem.FilesystemEvent
+}
+
+func (p *pollWatcher) collect() {
+	for event := range p.w.Events() {
+		p.mu.Lock()
+		if len(p.events) >= maxWatcherEvents {
+			p.events = p.events[1:]
+		}
+		p.events = append(p.events, event)
+		p.mu.Unlock()
+	}
+}
+
+func (p *pollWatcher) drain() []*filesystem.FilesystemEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	events := p.events
+	p.events = nil
+	return events
+}
+
+type watcherTable struct {
+	mu       sync.Mutex
+	watchers map[string]*pollWatcher
+}
+
+func newWatcherTable() *watcherTable {
+	return &watcherTable{watchers: make(map[string]*pollWatcher)}
+}
+
+func (t *watcherTable) add(w *dirWatcher) string {
+	p := &pollWatcher{w: w}
+	go p.collect()
+
+	id := uuid.NewString()
+	t.mu.Lock()
+	t.watchers[id] = p
+	t.mu.Unlock()
+	return id
+}
+
+func (t *watcherTable) get(id string) (*pollWatcher, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.watchers[id]
+	if !ok {
+		return nil, connect.NewError(connect.CodeNotFound, fmt.Errorf("watcher %s not found", id))
+	}
+	return p, nil
+}
+
+func (t *watcherTable) remove(id string) error {
+	t.mu.Lock()
+	p, ok := t.watchers[id]
+	delete(t.watchers, id)
+	t.mu.Unlock()
+
+	if !ok {
+		return connect.NewError(connect.CodeNotFound, fmt.Errorf("watcher %s not found", id))
+	}
+	return p.w.Close()
+}
+
+func (t *watcherTable) closeAll() {
+	t.mu.Lock()
+	watchers := t.watchers
+	t.watchers = make(map[string]*pollWatcher)
+	t.mu.Unlock()
+
+	for _, p := range watchers {
+		p.w.Close()
+	}
+}
+
+func (f *filesystemService) watcher(header http.Header, path string, recursive bool) (*dirWatcher, error) {
+	path, _, err := f.path(header, path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := newDirWatcher(path, recursive)
+	if err != nil {
+		if connect.CodeOf(err) != connect.CodeUnknown {
+			return nil, err
+		}
+		return nil, fsError(err)
+	}
+	return w, nil
+}
+
+func (f *filesystemService) WatchDir(
+	ctx context.Context,
+	req *connect.Request[filesystem.WatchDirRequest],
+	stream *connect.ServerStream[filesystem.WatchDirResponse],
+) error {
+	w, err := f.watcher(req.Header(), req.Msg.GetPath(), req.Msg.GetRecursive())
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := stream.Send(&filesystem.WatchDirResponse{
+		Event: &filesystem.WatchDirResponse_Start{Start: &filesystem.WatchDirResponse_StartEvent{}},
+	}); err != nil {
+		return err
+	}
+
+	keepAlive := time.NewTicker(f.s.opt.keepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		var res *filesystem.WatchDirResponse
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.Events():
+			if !ok {
+				return nil
+			}
+			res = &filesystem.WatchDirResponse{
+				Event: &filesystem.WatchDirResponse_Filesystem{Filesystem: event},
+			}
+		case <-keepAlive.C:
+			res = &filesystem.WatchDirResponse{
+				Event: &filesystem.WatchDirResponse_Keepalive{Keepalive: &filesystem.WatchDirResponse_KeepAlive{}},
+			}
+		}
+
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *filesystemService) CreateWatcher(
+	ctx context.Context,
+	req *connect.Request[filesystem.CreateWatcherRequest],
+) (*connect.Response[filesystem.CreateWatcherResponse], error) {
+	w, err := f.watcher(req.Header(), req.Msg.GetPath(), req.Msg.GetRecursive())
+	if err != nil {
+		return nil, err
+	}
+
+	id := f.s.watchers.add(w)
+	return connect.NewResponse(&filesystem.CreateWatcherResponse{WatcherId: id}), nil
+}
+
+func (f *filesystemService) GetWatcherEvents(
+	ctx context.Context,
+	req *connect.Request[filesystem.GetWatcherEventsRequest],
+) (*connect.Response[filesystem.GetWatcherEventsResponse], error) {
+	p, err := f.s.watchers.get(req.Msg.GetWatcherId())
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&filesystem.GetWatcherEventsResponse{Events: p.drain()}), nil
+}
+
+func (f *filesystemService) RemoveWatcher(
+	ctx context.Context,
+	req *connect.Request[filesystem.RemoveWatcherRequest],
+) (*connect.Response[filesystem.RemoveWatcherResponse], error) {
+	if err := f.s.watchers.remove(req.Msg.GetWatcherId()); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&filesystem.RemoveWatcherResponse{}), nil
+}
@@ -0,0 +1,189 @@
+//go:build linux
+
+package envd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem"
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_MODIFY |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_ATTRIB
+
+// dirWatcher reports changes below a directory through inotify. Event names
+// are relative to the watched directory.
+type dirWatcher struct {
+	file      *os.File
+	root      string
+	recursive bool
+
+	mu  sync.Mutex
+	wds map[int]string
+
+	events    chan *filesystem.FilesystemEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newDirWatcher(root string, recursive bool) (*dirWatcher, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+
+	w := &dirWatcher{
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		root:      root,
+		recursive: recursive,
+		wds:       make(map[int]string),
+		events:    make(chan *filesystem.FilesystemEvent, 64),
+		done:      make(chan struct{}),
+	}
+	if err := w.add(root); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	go w.readLoop()
+	return w, nil
+}
+
+// add watches dir and, when recursive, every directory below it.
+func (w *dirWatcher) add(dir string) error {
+	if !w.recursive {
+		return w.addWatch(dir)
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return w.addWatch(path)
+	})
+}
+
+func (w *dirWatcher) addWatch(dir string) error {
+	conn, err := w.file.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var wd int
+	var addErr error
+	if err := conn.Control(func(fd uintptr) {
+		wd, addErr = unix.InotifyAddWatch(int(fd), dir, watchMask|unix.IN_ONLYDIR)
+	}); err != nil {
+		return err
+	}
+	if addErr != nil {
+		return fmt.Errorf("watch %s: %w", dir, addErr)
+	}
+
+	w.mu.Lock()
+	w.wds[wd] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *dirWatcher) Events() <-chan *filesystem.FilesystemEvent {
+	return w.events
+}
+
+func (w *dirWatcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.file.Close()
+	})
+	return err
+}
+
+func (w *dirWatcher) readLoop() {
+	defer close(w.events)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			wd := int(int32(binary.NativeEndian.Uint32(buf[off:])))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			size := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			name := string(bytes.TrimRight(
+				buf[off+unix.SizeofInotifyEvent:off+unix.SizeofInotifyEvent+size], "\x00"))
+			off += unix.SizeofInotifyEvent + size
+
+			if !w.handle(wd, mask, name) {
+				return
+			}
+		}
+	}
+}
+
+// handle turns one inotify event into a filesystem event. It returns false
+// once the watcher is closed.
+func (w *dirWatcher) handle(wd int, mask uint32, name string) bool {
+	w.mu.Lock()
+	dir, ok := w.wds[wd]
+	if mask&unix.IN_IGNORED != 0 {
+		delete(w.wds, wd)
+	}
+	w.mu.Unlock()
+	if !ok || len(name) == 0 {
+		return true
+	}
+
+	path := filepath.Join(dir, name)
+	var typ filesystem.EventType
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		typ = filesystem.EventType_EVENT_TYPE_CREATE
+		if w.recursive && mask&unix.IN_ISDIR != 0 {
+			w.add(path)
+		}
+	case mask&unix.IN_MODIFY != 0:
+		typ = filesystem.EventType_EVENT_TYPE_WRITE
+	case mask&unix.IN_DELETE != 0:
+		typ = filesystem.EventType_EVENT_TYPE_REMOVE
+	case mask&unix.IN_MOVED_FROM != 0:
+		typ = filesystem.EventType_EVENT_TYPE_RENAME
+	case mask&unix.IN_ATTRIB != 0:
+		typ = filesystem.EventType_EVENT_TYPE_CHMOD
+	default:
+		return true
+	}
+
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		rel = path
+	}
+
+	select {
+	case w.events <- &filesystem.FilesystemEvent{Name: rel, Type: typ}:
+		return true
+	case <-w.done:
+		return false
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package envd
+
+import (
+	"errors"
+
+	"connectrpc.com/connect"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem"
+)
+
+type dirWatcher struct {
+	events chan *filesystem.FilesystemEvent
+}
+
+func newDirWatcher(root string, recursive bool) (*dirWatcher, error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("watching is only supported on linux"))
+}
+
+func (w *dirWatcher) Events() <-chan *filesystem.FilesystemEvent {
+	return w.events
+}
+
+func (w *dirWatcher) Close() error {
+	return nil
+}
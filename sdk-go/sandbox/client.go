@@ -0,0 +1,228 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dubonzi/otelresty"
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/mel2oo/go-dkit/ext"
+	"github.com/mel2oo/go-dkit/otel"
+)
+
+const defaultListPageSize = 100
+
+// maxListPages bounds ListAll, in case the backend never returns a short page.
+const maxListPages = 1000
+
+// Client talks to the secvirt API without being bound to a single sandbox.
+type Client struct {
+	api   *resty.Client               // API客户端
+	proxy *resty.Client               // 沙箱代理客户端
+	ops   map[Operation]*resty.Client // 按操作定制重试策略的API客户端
+	http  *http.Client                // 所有客户端共享的连接池
+	opts  []Option
+}
+
+func NewClient(opts ...Option) *Client {
+	opt := newOptions()
+	for _, o := range opts {
+		o(opt)
+	}
+
+	client := newClient(opt)
+	client.opts = opts
+	return client
+}
+
+func newClient(opt *Options) *Client {
+	httpClient := opt.client()
+
+	apiBaseUrl := fmt.Sprintf("%s://%s:%d", opt.scheme, opt.host, opt.apiPort)
+	apiClient := newRestyClient(httpClient, apiBaseUrl, opt.retryPolicy(""))
+
+	ops := make(map[Operation]*resty.Client)
+	for op, policy := range opt.retryPolicies {
+		if op == OpProxy {
+			continue
+		}
+		ops[op] = newRestyClient(httpClient, apiBaseUrl, policy)
+	}
+
+	prxBaseUrl := fmt.Sprintf("%s://%s:%d", opt.scheme, opt.host, opt.proxyPort)
+	prxClient := newRestyClient(httpClient, prxBaseUrl, opt.retryPolicy(OpProxy))
+
+	return &Client{
+		api:   apiClient,
+		proxy: prxClient,
+		ops:   ops,
+		http:  httpClient,
+	}
+}
+
+func newRestyClient(httpClient *http.Client, baseUrl string, policy RetryPolicy) *resty.Client {
+	// resty sets its own cookie jar on the client, so hand it a copy; the
+	// transport and its connection pool stay shared.
+	hc := *httpClient
+	client := resty.NewWithClient(&hc)
+	client.SetRetryCount(policy.Count)
+	client.SetRetryWaitTime(policy.WaitTime)
+	client.SetRetryMaxWaitTime(policy.MaxWaitTime)
+	client.AddRetryCondition(func(r *resty.Response, err error) bool {
+		if err != nil {
+			return true
+		}
+
+		// A 501 stays a 501, e.g. a backend without snapshots.
+		return (r.StatusCode() >= 500 && r.StatusCode() != 501) || r.StatusCode() == 429
+	})
+	client.SetBaseURL(baseUrl)
+	client.OnAfterResponse(setErrorStatus)
+	otelresty.TraceClient(client,
+		otelresty.WithSpanNameFormatter(otel.RestySpanNameFormatter),
+		otelresty.WithTracerProvider(otel.Standard().TracerProvider),
+		otelresty.WithPropagators(otel.Standard().Propagators),
+	)
+	return client
+}
+
+func (c *Client) ApiRequest(ctx context.Context) *resty.Request {
+	req := c.api.R()
+	req.SetContext(ctx)
+	ext.InjectHeader(ctx, req.Header)
+	return req
+}
+
+// request builds an API request with the retry policy of op. Mutating
+// operations carry an Idempotency-Key that stays the same across retries, so
+// the server can drop a replay of a request it already executed.
+func (c *Client) request(ctx context.Context, op Operation) *resty.Request {
+	api := c.api
+	if client, ok := c.ops[op]; ok {
+		api = client
+	}
+
+	req := api.R()
+	req.SetContext(ctx)
+	ext.InjectHeader(ctx, req.Header)
+	if op.mutating() {
+		req.SetHeader(IdempotencyKeyHeader, uuid.NewString())
+	}
+	return req
+}
+
+// ListFilter narrows down the sandboxes returned by List. Zero values are
+// ignored.
+type ListFilter struct {
+	User          string
+	Template      TemplateType
+	State         State
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// Page starts at 1. PageSize defaults to 100.
+	Page     int
+	PageSize int
+}
+
+func (f ListFilter) query() map[string]string {
+	query := make(map[string]string)
+	if len(f.User) > 0 {
+		query["user_id"] = f.User
+	}
+	if len(f.Template) > 0 {
+		query["template"] = string(f.Template)
+	}
+	if len(f.State) > 0 {
+		query["state"] = string(f.State)
+	}
+	if !f.CreatedAfter.IsZero() {
+		query["created_after"] = f.CreatedAfter.Format(time.RFC3339)
+	}
+	if !f.CreatedBefore.IsZero() {
+		query["created_before"] = f.CreatedBefore.Format(time.RFC3339)
+	}
+
+	page, pageSize := f.Page, f.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = defaultListPageSize
+	}
+	query["page"] = strconv.Itoa(page)
+	query["page_size"] = strconv.Itoa(pageSize)
+	return query
+}
+
+// List returns a single page of sandboxes matching filter.
+func (c *Client) List(ctx context.Context, filter ListFilter) ([]SandboxDetail, error) {
+	resp, err := c.request(ctx, OpList).
+		SetQueryParams(filter.query()).
+		SetResult([]SandboxDetail{}).
+		SetError(ErrorResponse{}).
+		Get("/secvirt/v2/sandboxes")
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, resp.Error().(*ErrorResponse)
+	}
+
+	return *resp.Result().(*[]SandboxDetail), nil
+}
+
+// ListAll walks every page starting at filter.Page and returns all matches.
+func (c *Client) ListAll(ctx context.Context, filter ListFilter) ([]SandboxDetail, error) {
+	if filter.Page <= 0 {
+		filter.Page = 1
+	}
+	if filter.PageSize <= 0 {
+		filter.PageSize = defaultListPageSize
+	}
+
+	details := make([]SandboxDetail, 0)
+	var first string
+	for range maxListPages {
+		page, err := c.List(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		// A backend that ignores page returns the same page again.
+		if len(page) == 0 || page[0].ID == first {
+			return details, nil
+		}
+		first = page[0].ID
+		details = append(details, page...)
+
+		if len(page) < filter.PageSize {
+			return details, nil
+		}
+		filter.Page++
+	}
+	return nil, fmt.Errorf("list sandboxes: more than %d pages", maxListPages)
+}
+
+// Attach connects to an existing sandbox with the options of the client.
+func (c *Client) Attach(ctx context.Context, id string, opts ...Option) (*Sandbox, error) {
+	return Attach(ctx, id, append(c.opts, opts...)...)
+}
+
+// DestroySandbox destroys a sandbox by ID, whatever its state.
+func (c *Client) DestroySandbox(ctx context.Context, id string) error {
+	resp, err := c.request(ctx, OpDestroy).
+		SetError(ErrorResponse{}).
+		Post("/secvirt/v2/sandboxes/" + id + "/destroy")
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return resp.Error().(*ErrorResponse)
+	}
+
+	return nil
+}
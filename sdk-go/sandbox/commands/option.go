@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"net/http"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+)
+
+type Option func(*Options)
+
+type Options struct {
+	httpClient *http.Client
+}
+
+func newOptions() *Options {
+	return &Options{
+		httpClient: spec.DefaultHTTPClient(),
+	}
+}
+
+// WithHTTPClient replaces the client built on spec.DefaultTransport.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) { o.httpClient = client }
+}
@@ -1,5 +1,23 @@
 package commands
 
+import "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process"
+
+// Selector picks the process a call acts on.
+type Selector struct {
+	selector *process.ProcessSelector
+}
+
+func ByPid(pid uint32) Selector {
+	return Selector{selector: pidSelector(pid)}
+}
+
+// ByTag picks the running process started with Spec.Tag.
+func ByTag(tag string) Selector {
+	return Selector{selector: &process.ProcessSelector{
+		Selector: &process.ProcessSelector_Tag{Tag: tag},
+	}}
+}
+
 type ProcessInfo struct {
 	pid  uint32
 	tag  string
@@ -9,6 +27,37 @@ type ProcessInfo struct {
 	cwd  string
 }
 
+func (p ProcessInfo) Pid() uint32 {
+	return p.pid
+}
+
+// Tag is empty for processes started without one.
+func (p ProcessInfo) Tag() string {
+	return p.tag
+}
+
+func (p ProcessInfo) Cmd() string {
+	return p.cmd
+}
+
+func (p ProcessInfo) Args() []string {
+	return p.args
+}
+
+func (p ProcessInfo) Envs() map[string]string {
+	return p.envs
+}
+
+func (p ProcessInfo) Cwd() string {
+	return p.cwd
+}
+
+func pidSelector(pid uint32) *process.ProcessSelector {
+	return &process.ProcessSelector{
+		Selector: &process.ProcessSelector_Pid{Pid: pid},
+	}
+}
+
 type PtySize struct {
 	Rows uint32
 	Cols uint32
@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// truncatedMarker replaces the output dropped between head and tail.
+const truncatedMarker = "\n[... %d bytes truncated ...]\n"
+
+// FileWriter is the part of filesystem.Filesystem that WithSpill needs.
+type FileWriter interface {
+	Write(ctx context.Context, path string, source any) error
+}
+
+// WithOutputLimit keeps at most the first head and the last tail bytes of
+// stdout and of stderr in CommandResult. Whatever lies in between is replaced
+// by a marker and counted in StdoutTruncated and StderrTruncated.
+func WithOutputLimit(head, tail int) HandleOption {
+	return func(ho *HandleOptions) {
+		ho.limited = true
+		ho.head, ho.tail = max(head, 0), max(tail, 0)
+	}
+}
+
+// WithSpill writes the complete stdout and stderr to files inside the
+// sandbox, whatever the output limit, and sets StdoutPath and StderrPath. An
+// empty path skips that stream, a stream without output leaves no file.
+func WithSpill(fs FileWriter, stdoutPath, stderrPath string) HandleOption {
+	return func(ho *HandleOptions) {
+		ho.spillFS = fs
+		ho.spillPaths = [2]string{stdoutPath, stderrPath}
+	}
+}
+
+// capture keeps the output of one stream, all of it or its head and tail.
+type capture struct {
+	limited bool
+	head    int
+	buf     []byte
+	tail    ring
+	dropped int64
+}
+
+func newCapture(opt HandleOptions) *capture {
+	return &capture{
+		limited: opt.limited,
+		head:    opt.head,
+		tail:    ring{size: opt.tail},
+	}
+}
+
+func (c *capture) Write(p []byte) {
+	if !c.limited {
+		c.buf = append(c.buf, p...)
+		return
+	}
+
+	if n := min(c.head-len(c.buf), len(p)); n > 0 {
+		c.buf = append(c.buf, p[:n]...)
+		p = p[n:]
+	}
+	c.dropped += c.tail.Write(p)
+}
+
+func (c *capture) String() string {
+	if c.dropped == 0 {
+		return string(c.buf) + string(c.tail.Bytes())
+	}
+	return string(c.buf) + fmt.Sprintf(truncatedMarker, c.dropped) + string(c.tail.Bytes())
+}
+
+// ring holds the last size bytes written to it.
+type ring struct {
+	size int
+	buf  []byte
+	pos  int
+	full bool
+}
+
+// Write returns how many bytes fell out of the ring.
+func (r *ring) Write(p []byte) int64 {
+	if len(p) == 0 {
+		return 0
+	}
+	if r.size == 0 {
+		return int64(len(p))
+	}
+	if r.buf == nil {
+		r.buf = make([]byte, r.size)
+	}
+
+	before := r.len()
+	var dropped int64
+	if len(p) > r.size {
+		dropped = int64(len(p) - r.size)
+		p = p[len(p)-r.size:]
+	}
+
+	n := copy(r.buf[r.pos:], p)
+	copy(r.buf, p[n:])
+	if r.pos += len(p); r.pos >= r.size {
+		r.pos -= r.size
+		r.full = true
+	}
+	return dropped + int64(before+len(p)-r.len())
+}
+
+func (r *ring) len() int {
+	if r.full {
+		return r.size
+	}
+	return r.pos
+}
+
+func (r *ring) Bytes() []byte {
+	if !r.full {
+		return r.buf[:r.pos]
+	}
+	b := make([]byte, 0, r.size)
+	b = append(b, r.buf[r.pos:]...)
+	return append(b, r.buf[:r.pos]...)
+}
+
+// spill uploads one output stream to a file. The upload starts with the
+// first byte, so a stream without output leaves no file.
+type spill struct {
+	ctx  context.Context
+	fs   FileWriter
+	path string
+	w    *io.PipeWriter
+	done chan error
+	err  error
+}
+
+func newSpill(ctx context.Context, opt HandleOptions, fd int) *spill {
+	if opt.spillFS == nil || opt.spillPaths[fd] == "" {
+		return nil
+	}
+	return &spill{ctx: ctx, fs: opt.spillFS, path: opt.spillPaths[fd]}
+}
+
+func (s *spill) Write(p []byte) {
+	if s == nil || s.err != nil {
+		return
+	}
+
+	if s.w == nil {
+		pr, pw := io.Pipe()
+		s.w, s.done = pw, make(chan error, 1)
+		go func() {
+			err := s.fs.Write(s.ctx, s.path, pr)
+			if err == nil {
+				err = io.ErrClosedPipe
+			}
+			// Unblock the writer if the upload gave up early.
+			pr.CloseWithError(err)
+			s.done <- err
+		}()
+	}
+
+	if _, err := s.w.Write(p); err != nil {
+		s.err = err
+	}
+}
+
+// close finishes the upload and returns the path of the file, empty if
+// nothing was written.
+func (s *spill) close() (string, error) {
+	if s == nil || s.w == nil {
+		return "", nil
+	}
+
+	s.w.Close()
+	s.w = nil
+	err := <-s.done
+	if s.err != nil {
+		err = s.err
+	} else if err == io.ErrClosedPipe {
+		err = nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to spill output to %s: %w", s.path, err)
+	}
+	return s.path, nil
+}
@@ -4,9 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net"
-	"net/http"
-	"runtime"
 	"strings"
 	"time"
 
@@ -25,37 +22,41 @@ const (
 	runRetryBackoff     = 100 * time.Millisecond
 )
 
-func NewCmd(baseUrl, sandboxID, user string) *Cmd {
-	dialer := &net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
-	}
-
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			DialContext:           dialer.DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-			MaxIdleConnsPerHost:   runtime.GOMAXPROCS(0) + 1,
-		},
+func NewCmd(baseUrl, sandboxID, user string, opts ...Option) *Cmd {
+	opt := newOptions()
+	for _, o := range opts {
+		o(opt)
 	}
 
 	return &Cmd{
 		client: psConnect.NewProcessClient(
-			httpClient,
+			opt.httpClient,
 			baseUrl,
 			connect.WithInterceptors(
 				spec.NewHeaderInterceptor(spec.DefaultEnvdPort, sandboxID, user),
+				spec.NewErrorInterceptor(),
 			),
 		),
 	}
 }
 
-func (c *Cmd) List(ctx context.Context) ([]ProcessInfo, error) {
+type ListOption func(*ListOptions)
+
+type ListOptions struct {
+	tag *string
+}
+
+// WithTag lists only the process started with tag.
+func WithTag(tag string) ListOption {
+	return func(o *ListOptions) { o.tag = &tag }
+}
+
+func (c *Cmd) List(ctx context.Context, opts ...ListOption) ([]ProcessInfo, error) {
+	opt := &ListOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
 	res, err := c.client.List(ctx, connect.NewRequest(&process.ListRequest{}))
 	if err != nil {
 		return nil, err
@@ -63,6 +64,10 @@ func (c *Cmd) List(ctx context.Context) ([]ProcessInfo, error) {
 
 	processes := make([]ProcessInfo, 0)
 	for _, v := range res.Msg.GetProcesses() {
+		if opt.tag != nil && v.GetTag() != *opt.tag {
+			continue
+		}
+
 		config := v.GetConfig()
 		processes = append(processes, ProcessInfo{
 			pid:  v.Pid,
@@ -77,22 +82,16 @@ func (c *Cmd) List(ctx context.Context) ([]ProcessInfo, error) {
 }
 
 func (c *Cmd) Kill(ctx context.Context, pid uint32) error {
-	_, err := c.client.SendSignal(ctx, connect.NewRequest(&process.SendSignalRequest{
-		Process: &process.ProcessSelector{
-			Selector: &process.ProcessSelector_Pid{Pid: pid},
-		},
-		Signal: process.Signal_SIGNAL_SIGKILL,
-	}))
-	return err
+	return c.Signal(ctx, ByPid(pid), SIGKILL)
 }
 
 func (c *Cmd) SendStdin(ctx context.Context, pid uint32, data []byte) error {
+	return c.SendStdinTo(ctx, ByPid(pid), data)
+}
+
+func (c *Cmd) SendStdinTo(ctx context.Context, sel Selector, data []byte) error {
 	_, err := c.client.SendInput(ctx, connect.NewRequest(&process.SendInputRequest{
-		Process: &process.ProcessSelector{
-			Selector: &process.ProcessSelector_Pid{
-				Pid: pid,
-			},
-		},
+		Process: sel.selector,
 		Input: &process.ProcessInput{
 			Input: &process.ProcessInput_Stdin{
 				Stdin: data,
@@ -102,35 +101,67 @@ func (c *Cmd) SendStdin(ctx context.Context, pid uint32, data []byte) error {
 	return err
 }
 
-func (c *Cmd) Start(
-	ctx context.Context,
-	cmd string,
-	envs map[string]string,
-	cwd string,
-	stdin bool,
-) (*CommandHandle, error) {
-	stream, err := c.client.Start(ctx, connect.NewRequest(&process.StartRequest{
-		Process: &process.ProcessConfig{
-			Cmd:  "/bin/bash",
-			Args: []string{"-l", "-c", cmd},
-			Envs: envs,
-			Cwd:  &cwd,
-		},
-		Stdin: &stdin,
-	}))
+// Exec starts the process described by spec.
+func (c *Cmd) Exec(ctx context.Context, spec Spec) (*CommandHandle, error) {
+	req, err := spec.request()
 	if err != nil {
 		return nil, err
 	}
 
+	// The stream follows ctx but carries no deadline: a deadline is sent
+	// along and could end the stream on the server before ctx reports it.
+	parent := ctx
+	ctx, cancelCause := context.WithCancelCause(context.WithoutCancel(parent))
+	stop := context.AfterFunc(parent, func() { cancelCause(context.Cause(parent)) })
+	cancel := func() {
+		stop()
+		cancelCause(context.Canceled)
+	}
+
+	stream, err := c.client.Start(ctx, connect.NewRequest(req))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
 	if !stream.Receive() {
-		return nil, fmt.Errorf("failed to start process: %s", stream.Err())
+		cancel()
+		return nil, fmt.Errorf("failed to start process: %w", stream.Err())
 	}
 
-	return &CommandHandle{
-		pid:         stream.Msg().Event.GetStart().Pid,
-		kill:        c.Kill,
-		startStream: stream,
-	}, nil
+	h := &CommandHandle{
+		pid:    stream.Msg().Event.GetStart().Pid,
+		kill:   c.Kill,
+		client: c.client,
+		stream: startStream{stream},
+		ctx:    ctx,
+		cancel: cancel,
+		opt: HandleOptions{
+			onStdout:    spec.OnStdout,
+			onStderr:    spec.OnStderr,
+			killGrace:   spec.KillGrace,
+			idleTimeout: spec.IdleTimeout,
+		},
+	}
+	h.setTimeout(spec.Timeout)
+	return h, nil
+}
+
+// Start runs cmd with a bash login shell.
+func (c *Cmd) Start(
+	ctx context.Context,
+	cmd string,
+	envs map[string]string,
+	cwd string,
+	stdin bool,
+) (*CommandHandle, error) {
+	return c.Exec(ctx, Spec{
+		Command: cmd,
+		Login:   true,
+		Envs:    envs,
+		Cwd:     cwd,
+		Stdin:   stdin,
+	})
 }
 
 func (c *Cmd) Run(
@@ -184,7 +215,13 @@ func (c *Cmd) runCommand(
 		return nil, err
 	}
 
-	return h.Wait(ctx, opts...)
+	res, err := h.Wait(ctx, opts...)
+	if err != nil && ctx.Err() != nil {
+		// The stream ended with ctx, the process would keep running.
+		go h.stop()
+		return nil, err
+	}
+	return res, err
 }
 
 func shouldRetryRun(err error) bool {
@@ -198,24 +235,21 @@ func shouldRetryRun(err error) bool {
 }
 
 func (c *Cmd) Connect(ctx context.Context, pid uint32) (*CommandHandle, error) {
-	stream, err := c.client.Connect(ctx, connect.NewRequest(&process.ConnectRequest{
-		Process: &process.ProcessSelector{
-			Selector: &process.ProcessSelector_Pid{
-				Pid: pid,
-			},
-		},
-	}))
+	return c.ConnectTo(ctx, ByPid(pid))
+}
+
+// ConnectTo attaches to a running process, e.g. ByTag after the SDK
+// restarted. Wait then streams its output from now on and its exit code.
+func (c *Cmd) ConnectTo(ctx context.Context, sel Selector) (*CommandHandle, error) {
+	stream, pid, err := attach(ctx, c.client, sel, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	if !stream.Receive() {
-		return nil, errors.New("failed to start process")
-	}
-
 	return &CommandHandle{
-		pid:           stream.Msg().Event.GetStart().Pid,
-		kill:          c.Kill,
-		connectStream: stream,
+		pid:    pid,
+		kill:   c.Kill,
+		client: c.client,
+		stream: stream,
 	}, nil
 }
@@ -1,40 +1,85 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"iter"
 
 	"github.com/sirupsen/logrus"
 )
 
+// DefaultMaxLineSize is the longest stdout line a Stream accepts.
+const DefaultMaxLineSize = 8 << 20
+
+// ErrLineTooLong ends a Stream whose command printed a line longer than the
+// maximum line size.
+var ErrLineTooLong = errors.New("commands: stream line too long")
+
 type Decoder[T any] interface {
 	Decode(data []byte) (T, error)
 }
 
+type StreamOption func(*StreamOptions)
+
+type StreamOptions struct {
+	maxLineSize int
+}
+
+// WithMaxLineSize sets the longest stdout line, DefaultMaxLineSize if not set.
+func WithMaxLineSize(n int) StreamOption {
+	return func(o *StreamOptions) { o.maxLineSize = n }
+}
+
+// Stream decodes the stdout of a command as newline delimited JSON. Lines
+// that are not JSON, such as banners, are skipped.
 type Stream[T any] struct {
 	handle *CommandHandle
 	events chan []byte
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	decoder Decoder[T]
-	err     error
-	done    bool
+	// err is set before events is closed.
+	err error
 }
 
-func NewStream[T any](ctx context.Context, handle *CommandHandle, decoder Decoder[T]) *Stream[T] {
+// StreamEvent is a message of a Stream, or an error.
+type StreamEvent[T any] struct {
+	Value T
+	Err   error
+}
+
+func NewStream[T any](ctx context.Context, handle *CommandHandle, decoder Decoder[T],
+	opts ...StreamOption) *Stream[T] {
+	opt := &StreamOptions{maxLineSize: DefaultMaxLineSize}
+	for _, o := range opts {
+		o(opt)
+	}
+
 	s := &Stream[T]{
 		handle: handle,
 		events: make(chan []byte),
 
 		decoder: decoder,
 	}
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	lines := &lineSplitter{max: opt.maxLineSize, emit: s.emit}
 
 	go func() {
+		// The output is only decoded, Wait keeps none of it.
 		_, err := s.handle.Wait(ctx,
+			WithOutputLimit(0, 0),
 			WithStdout(
 				func(b []byte) {
-					if json.Valid(b) {
-						s.events <- b
+					if lines.err != nil {
+						return
+					}
+					if err := lines.Write(b); err != nil {
+						s.handle.Kill()
 					}
 				},
 			),
@@ -45,8 +90,11 @@ func NewStream[T any](ctx context.Context, handle *CommandHandle, decoder Decode
 				},
 			),
 		)
+		lines.Flush()
+		if lines.err != nil {
+			err = lines.err
+		}
 		s.err = err
-		s.done = true
 		s.handle.kill(context.Background(), s.handle.pid)
 		close(s.events)
 	}()
@@ -54,29 +102,121 @@ func NewStream[T any](ctx context.Context, handle *CommandHandle, decoder Decode
 	return s
 }
 
-func (s *Stream[T]) Recv() (T, error) {
-	var nxt T
-
-	if s.err != nil {
-		return nxt, s.err
+// emit hands a line to Recv, unless the stream was closed.
+func (s *Stream[T]) emit(line []byte) {
+	if !json.Valid(line) {
+		return
 	}
 
-	if s.done && len(s.events) == 0 {
-		return nxt, io.EOF
+	select {
+	case s.events <- line:
+	case <-s.ctx.Done():
 	}
+}
+
+// Recv returns the next message. It returns io.EOF once the command exited
+// successfully and all of its output was received.
+func (s *Stream[T]) Recv() (T, error) {
+	v, err, _ := s.recv()
+	return v, err
+}
+
+// recv also reports whether the error ended the stream.
+func (s *Stream[T]) recv() (T, error, bool) {
+	var nxt T
 
 	raw, ok := <-s.events
 	if !ok {
-		return nxt, io.EOF
+		if s.err != nil {
+			return nxt, s.err, true
+		}
+		return nxt, io.EOF, true
+	}
+
+	v, err := s.decoder.Decode(raw)
+	return v, err, false
+}
+
+// All iterates over the messages. A message that fails to decode is yielded
+// with its error and the iteration goes on; an error that ended the stream
+// is yielded last.
+func (s *Stream[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			v, err, end := s.recv()
+			if errors.Is(err, io.EOF) && end {
+				return
+			}
+			if !yield(v, err) || end {
+				return
+			}
+		}
 	}
+}
 
-	return s.decoder.Decode(raw)
+// Events is All as a channel, closed after the last message. Close the
+// stream when not reading it to the end.
+func (s *Stream[T]) Events() <-chan StreamEvent[T] {
+	ch := make(chan StreamEvent[T])
+	go func() {
+		defer close(ch)
+		for v, err := range s.All() {
+			select {
+			case ch <- StreamEvent[T]{Value: v, Err: err}:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
 }
 
+// Close kills the command and stops delivering messages.
 func (s *Stream[T]) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
 	if s.handle != nil {
 		s.handle.Kill()
 	}
 
 	return nil
 }
+
+// lineSplitter reassembles output chunks into lines without the newline.
+type lineSplitter struct {
+	max  int
+	buf  []byte
+	emit func([]byte)
+	err  error
+}
+
+func (l *lineSplitter) Write(p []byte) error {
+	for len(p) > 0 && l.err == nil {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			l.buf = append(l.buf, p...)
+			if len(l.buf) > l.max {
+				l.err, l.buf = ErrLineTooLong, nil
+			}
+			break
+		}
+
+		line := append(l.buf, p[:i]...)
+		l.buf, p = nil, p[i+1:]
+		if len(line) > l.max {
+			l.err = ErrLineTooLong
+			break
+		}
+		l.emit(bytes.TrimSuffix(line, []byte("\r")))
+	}
+	return l.err
+}
+
+// Flush emits a last line that has no newline.
+func (l *lineSplitter) Flush() {
+	if len(l.buf) > 0 && l.err == nil {
+		l.emit(l.buf)
+	}
+	l.buf = nil
+}
@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Sandbox is the part of sandbox.Sandbox that Command needs.
+type Sandbox interface {
+	Cmd() *Cmd
+}
+
+// ExecCmd runs a process inside the sandbox the way os/exec.Cmd runs one
+// locally. The zero value is not usable, create it with Command.
+type ExecCmd struct {
+	// Path is executed directly, without a shell. Args holds the command
+	// line including Path as Args[0].
+	Path string
+	Args []string
+
+	// Env holds "k=v" variables added to the sandbox environment.
+	Env []string
+	// Dir is the working directory, the user's home if empty.
+	Dir string
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Result is set by Wait once the process exited.
+	Result *CommandResult
+
+	ctx    context.Context
+	cmd    *Cmd
+	handle *CommandHandle
+
+	closeAfterWait []io.Closer
+	writeMu        sync.Mutex
+	writeErr       error
+	done           chan struct{}
+	waited         bool
+	err            error
+}
+
+// Command returns an ExecCmd that runs name with args in sbx. The process is
+// killed when ctx is done before it exits.
+func Command(ctx context.Context, sbx Sandbox, name string, args ...string) *ExecCmd {
+	return &ExecCmd{
+		Path: name,
+		Args: append([]string{name}, args...),
+		ctx:  ctx,
+		cmd:  sbx.Cmd(),
+	}
+}
+
+// Pid is the process id inside the sandbox, zero before Start.
+func (c *ExecCmd) Pid() uint32 {
+	if c.handle == nil {
+		return 0
+	}
+	return c.handle.Pid()
+}
+
+func (c *ExecCmd) String() string {
+	return strings.Join(c.Args, " ")
+}
+
+func (c *ExecCmd) Start() error {
+	if c.handle != nil {
+		return errors.New("commands: already started")
+	}
+
+	argv := []string{c.Path}
+	if len(c.Args) > 1 {
+		argv = append(argv, c.Args[1:]...)
+	}
+	envs := make(map[string]string, len(c.Env))
+	for _, kv := range c.Env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			envs[k] = v
+		}
+	}
+
+	h, err := c.cmd.Exec(c.ctx, Spec{
+		Argv:  argv,
+		Envs:  envs,
+		Cwd:   c.Dir,
+		Stdin: c.Stdin != nil,
+	})
+	if err != nil {
+		c.closePipes()
+		return err
+	}
+	c.handle = h
+
+	if c.Stdin != nil {
+		go func() {
+			w := h.Stdin()
+			io.Copy(w, c.Stdin)
+			w.Close()
+		}()
+	}
+
+	c.done = make(chan struct{})
+	go c.wait()
+	return nil
+}
+
+func (c *ExecCmd) wait() {
+	defer close(c.done)
+	defer c.closePipes()
+
+	// Output goes through the handle's pipes, so the result keeps none of it.
+	var copies sync.WaitGroup
+	c.copyOutput(&copies, c.Stdout, c.handle.Stdout)
+	c.copyOutput(&copies, c.Stderr, c.handle.Stderr)
+
+	res, err := c.handle.Wait(c.ctx)
+	copies.Wait()
+
+	var exitErr *CommandExitError
+	switch {
+	case errors.As(err, &exitErr):
+		c.Result = &exitErr.Result
+	case err != nil && c.ctx.Err() != nil:
+		c.handle.Kill()
+		err = c.ctx.Err()
+	case err == nil:
+		c.Result = res
+		err = c.writeErr
+	}
+	c.err = err
+}
+
+// copyOutput copies a pipe of the handle to w and keeps the first error. After
+// an error the pipe is closed, which discards the rest of the output.
+func (c *ExecCmd) copyOutput(wg *sync.WaitGroup, w io.Writer, pipe func() io.ReadCloser) {
+	if w == nil {
+		return
+	}
+	r := pipe()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := io.Copy(&lockedWriter{w: w, mu: &c.writeMu}, r); err != nil {
+			r.Close()
+			c.writeMu.Lock()
+			if c.writeErr == nil {
+				c.writeErr = err
+			}
+			c.writeMu.Unlock()
+		}
+	}()
+}
+
+// lockedWriter serializes the writes of both pipes, Stdout and Stderr may be
+// the same writer.
+type lockedWriter struct {
+	w  io.Writer
+	mu *sync.Mutex
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+func (c *ExecCmd) closePipes() {
+	for _, closer := range c.closeAfterWait {
+		closer.Close()
+	}
+	c.closeAfterWait = nil
+}
+
+// Wait waits for the process to exit and its output to be written. A non-zero
+// exit code is returned as *CommandExitError.
+func (c *ExecCmd) Wait() error {
+	if c.handle == nil {
+		return errors.New("commands: not started")
+	}
+	if c.waited {
+		return errors.New("commands: Wait was already called")
+	}
+	c.waited = true
+
+	<-c.done
+	return c.err
+}
+
+func (c *ExecCmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Output runs the command and returns its standard output.
+func (c *ExecCmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("commands: Stdout already set")
+	}
+	var stdout bytes.Buffer
+	c.Stdout = &stdout
+
+	err := c.Run()
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the command and returns its standard output and
+// standard error, interleaved as they arrived.
+func (c *ExecCmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("commands: Stdout already set")
+	}
+	if c.Stderr != nil {
+		return nil, errors.New("commands: Stderr already set")
+	}
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+
+	err := c.Run()
+	return out.Bytes(), err
+}
+
+// StdinPipe returns a pipe to the standard input of the process. Closing it
+// ends the input stream.
+func (c *ExecCmd) StdinPipe() (io.WriteCloser, error) {
+	if c.Stdin != nil {
+		return nil, errors.New("commands: Stdin already set")
+	}
+	if c.handle != nil {
+		return nil, errors.New("commands: StdinPipe after process started")
+	}
+	pr, pw := io.Pipe()
+	c.Stdin = pr
+	c.closeAfterWait = append(c.closeAfterWait, pr)
+	return pw, nil
+}
+
+// StdoutPipe returns a pipe to the standard output of the process. Read it
+// completely before calling Wait, output is not buffered.
+func (c *ExecCmd) StdoutPipe() (io.ReadCloser, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("commands: Stdout already set")
+	}
+	if c.handle != nil {
+		return nil, errors.New("commands: StdoutPipe after process started")
+	}
+	pr, pw := io.Pipe()
+	c.Stdout = pw
+	c.closeAfterWait = append(c.closeAfterWait, pw)
+	return pr, nil
+}
+
+// StderrPipe is StdoutPipe for standard error.
+func (c *ExecCmd) StderrPipe() (io.ReadCloser, error) {
+	if c.Stderr != nil {
+		return nil, errors.New("commands: Stderr already set")
+	}
+	if c.handle != nil {
+		return nil, errors.New("commands: StderrPipe after process started")
+	}
+	pr, pw := io.Pipe()
+	c.Stderr = pw
+	c.closeAfterWait = append(c.closeAfterWait, pw)
+	return pr, nil
+}
@@ -1,13 +1,19 @@
 package commands
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"connectrpc.com/connect"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process"
+	psConnect "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process/processconnect"
 )
 
 type HandleOption func(*HandleOptions)
@@ -16,6 +22,15 @@ type HandleOptions struct {
 	onStdout func([]byte)
 	onStderr func([]byte)
 	onPty    func([]byte)
+
+	limited    bool
+	head, tail int
+	spillFS    FileWriter
+	spillPaths [2]string
+
+	timeout     time.Duration
+	killGrace   time.Duration
+	idleTimeout time.Duration
 }
 
 func WithStdout(fn func([]byte)) HandleOption {
@@ -30,93 +45,470 @@ func WithPty(fn func([]byte)) HandleOption {
 	return func(ho *HandleOptions) { ho.onPty = fn }
 }
 
+// WithIdleTimeout fails a stream that received neither output nor a
+// keepalive for d, see Spec.IdleTimeout.
+func WithIdleTimeout(d time.Duration) HandleOption {
+	return func(ho *HandleOptions) { ho.idleTimeout = d }
+}
+
+// ErrDisconnected is returned by Wait when the stream closed before the
+// process ended, e.g. after a network blip. Reconnect and Wait again.
+var ErrDisconnected = errors.New("commands: stream closed before the process ended")
+
+// ErrStreamStalled is returned by Wait when the stream stalled and
+// reconnecting did not help.
+var ErrStreamStalled = spec.ErrStreamStalled
+
+// maxStallReconnects bounds the reconnects in a row that bring no event.
+const maxStallReconnects = 3
+
+// eventStream is a Start or a Connect stream.
+type eventStream interface {
+	Receive() bool
+	Event() *process.ProcessEvent
+	Err() error
+	Close() error
+}
+
+type startStream struct {
+	*connect.ServerStreamForClient[process.StartResponse]
+}
+
+func (s startStream) Event() *process.ProcessEvent {
+	return s.Msg().GetEvent()
+}
+
+type connectStream struct {
+	*connect.ServerStreamForClient[process.ConnectResponse]
+	cancel context.CancelFunc
+}
+
+func (s connectStream) Event() *process.ProcessEvent {
+	return s.Msg().GetEvent()
+}
+
+func (s connectStream) Close() error {
+	defer s.cancel()
+	return s.ServerStreamForClient.Close()
+}
+
+// attach opens a Connect stream and reads its start event. A start event
+// that does not come within idleTimeout, when set, fails with
+// ErrStreamStalled.
+func attach(ctx context.Context, client psConnect.ProcessClient, sel Selector,
+	idleTimeout time.Duration) (eventStream, uint32, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	idle := spec.NewIdleTimer(idleTimeout, cancel)
+	stream, err := client.Connect(ctx, connect.NewRequest(&process.ConnectRequest{
+		Process: sel.selector,
+	}))
+	if err != nil {
+		idle.Stop()
+		cancel()
+		return nil, 0, err
+	}
+
+	ok := stream.Receive()
+	idle.Stop()
+	if !ok {
+		err := stream.Err()
+		stream.Close()
+		cancel()
+		if idle.Stalled() {
+			err = ErrStreamStalled
+		} else if err == nil {
+			err = ErrDisconnected
+		}
+		return nil, 0, fmt.Errorf("failed to connect process: %w", err)
+	}
+	return connectStream{stream, cancel}, stream.Msg().GetEvent().GetStart().GetPid(), nil
+}
+
 type CommandHandle struct {
-	pid           uint32
-	kill          func(context.Context, uint32) error
-	startStream   *connect.ServerStreamForClient[process.StartResponse]
-	connectStream *connect.ServerStreamForClient[process.ConnectResponse]
+	pid    uint32
+	kill   func(context.Context, uint32) error
+	client psConnect.ProcessClient
+	pty    bool
+
+	stdinMu sync.Mutex
+	stdin   *inputWriter
+
+	// Set by Exec: the stream context, its cancel and the spec callbacks.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Output is read from the stream by run, started by Wait or by the first
+	// Read from a pipe. mu guards the fields below. The stream is replaced by
+	// Reconnect and, after a stall, by run until Disconnect.
+	mu           sync.Mutex
+	stream       eventStream
+	disconnected bool
+	opt          HandleOptions
+	pipes        [2]*outputPipe
+	running      chan struct{}
+	result       *CommandResult
+	err          error
+
+	// The timeout, see process_timeout.go.
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut atomic.Bool
+	ended    chan struct{}
 }
 
 func (h *CommandHandle) Pid() uint32 {
 	return h.pid
 }
 
+// Stdin returns the input of the process. Writes arrive in order over a
+// single StreamInput call, Close sends EOF. For a pty session the input goes
+// to the terminal and Close only ends the stream.
+func (h *CommandHandle) Stdin() io.WriteCloser {
+	h.stdinMu.Lock()
+	defer h.stdinMu.Unlock()
+
+	if h.stdin == nil {
+		h.stdin = newInputWriter(h.client, pidSelector(h.pid), h.pty)
+	}
+	return h.stdin
+}
+
+// Stdout returns a pipe that receives the standard output of the process, or
+// the terminal output of a pty session. Output is not buffered: until it is
+// read the stream is not read either, which in turn blocks the process. Piped
+// output is left out of CommandResult. Closing the pipe discards the rest.
+func (h *CommandHandle) Stdout() io.ReadCloser {
+	return h.pipe(0)
+}
+
+// Stderr is Stdout for standard error.
+func (h *CommandHandle) Stderr() io.ReadCloser {
+	return h.pipe(1)
+}
+
+func (h *CommandHandle) pipe(fd int) io.ReadCloser {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pipes[fd] == nil {
+		pr, pw := io.Pipe()
+		h.pipes[fd] = &outputPipe{PipeReader: pr, w: pw, h: h}
+		if h.finished() {
+			pw.CloseWithError(h.pipeErr())
+		}
+	}
+	return h.pipes[fd]
+}
+
+type outputPipe struct {
+	*io.PipeReader
+	w *io.PipeWriter
+	h *CommandHandle
+}
+
+func (p *outputPipe) Read(b []byte) (int, error) {
+	p.h.start()
+	return p.PipeReader.Read(b)
+}
+
+// CombinedOutput waits for the process and returns its standard output and
+// standard error, interleaved as they arrived.
+func (h *CommandHandle) CombinedOutput(ctx context.Context) ([]byte, error) {
+	var out bytes.Buffer
+	write := func(b []byte) { out.Write(b) }
+	_, err := h.Wait(ctx, WithStdout(write), WithStderr(write), WithPty(write))
+	return out.Bytes(), err
+}
+
+// Disconnect closes the stream, the process keeps running.
 func (c *CommandHandle) Disconnect() error {
-	return c.startStream.Close()
+	c.mu.Lock()
+	c.disconnected = true
+	stream := c.stream
+	c.mu.Unlock()
+
+	if c.cancel != nil {
+		defer c.cancel()
+	}
+	return stream.Close()
 }
 
+// Reconnect replaces the stream with a new Connect stream to the same
+// process. Output sent while disconnected is lost. Do not call it while Wait
+// is running. Pipes end with the old stream: call Stdout and Stderr again for
+// the output of the new one.
+func (h *CommandHandle) Reconnect(ctx context.Context) error {
+	h.mu.Lock()
+	idleTimeout := h.opt.idleTimeout
+	h.mu.Unlock()
+
+	stream, _, err := attach(ctx, h.client, ByPid(h.pid), idleTimeout)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	old := h.stream
+	h.stream, h.disconnected = stream, false
+	h.running, h.result, h.err = nil, nil, nil
+	h.mu.Unlock()
+
+	old.Close()
+	return nil
+}
+
+// reattach replaces a stalled stream, see Reconnect. It gives up once the
+// handle was disconnected.
+func (h *CommandHandle) reattach(idleTimeout time.Duration) (eventStream, error) {
+	ctx := context.Background()
+	if h.ctx != nil {
+		ctx = h.ctx
+	}
+	stream, _, err := attach(ctx, h.client, ByPid(h.pid), idleTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.disconnected {
+		stream.Close()
+		return nil, ErrDisconnected
+	}
+	h.stream = stream
+	return stream, nil
+}
+
+// currentStream returns the stream, which reattach may replace.
+func (h *CommandHandle) currentStream() eventStream {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stream
+}
+
+// Wait waits for the process to exit. Options replace the callbacks for the
+// output still to come.
 func (h *CommandHandle) Wait(ctx context.Context, opts ...HandleOption) (*CommandResult, error) {
-	opt := &HandleOptions{}
+	h.mu.Lock()
 	for _, o := range opts {
-		o(opt)
-	}
-
-	var result = &CommandResult{}
-	var stdout strings.Builder
-	var stderr strings.Builder
-
-	for h.startStream.Receive() {
-		var event *process.ProcessEvent
-		if h.startStream != nil {
-			msg := h.startStream.Msg()
-			event = msg.GetEvent()
-		} else if h.connectStream != nil {
-			msg := h.connectStream.Msg()
-			event = msg.GetEvent()
-		} else {
-			return nil, errors.New("none stream for client")
+		o(&h.opt)
+	}
+	timeout := h.opt.timeout
+	h.mu.Unlock()
+
+	h.setTimeout(timeout)
+	<-h.start()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.result, h.err
+}
+
+// start runs run once per stream and returns a channel closed when it ended.
+func (h *CommandHandle) start() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.running == nil {
+		h.running = make(chan struct{})
+		go h.run(h.running)
+	}
+	return h.running
+}
+
+// finished reports whether run ended, h.mu must be held.
+func (h *CommandHandle) finished() bool {
+	if h.running == nil {
+		return false
+	}
+	select {
+	case <-h.running:
+		return true
+	default:
+		return false
+	}
+}
+
+// pipeErr is what readers see after the output, h.mu must be held.
+func (h *CommandHandle) pipeErr() error {
+	var exitErr *CommandExitError
+	if h.err == nil || errors.As(h.err, &exitErr) {
+		return nil
+	}
+	return h.err
+}
+
+func (h *CommandHandle) run(done chan struct{}) {
+	result, err := h.receive()
+	if h.cancel != nil {
+		h.cancel()
+	}
+
+	h.mu.Lock()
+	h.result, h.err = result, err
+	// Reconnect starts another run, with new pipes.
+	for i, p := range h.pipes {
+		if p != nil {
+			p.w.CloseWithError(h.pipeErr())
+			h.pipes[i] = nil
+		}
+	}
+	close(done)
+	h.mu.Unlock()
+}
+
+func (h *CommandHandle) receive() (*CommandResult, error) {
+	h.mu.Lock()
+	opt, stream := h.opt, h.stream
+	h.mu.Unlock()
+
+	var result *CommandResult
+	stdout, stderr := newCapture(opt), newCapture(opt)
+
+	ctx := context.Background()
+	if h.ctx != nil {
+		ctx = context.WithoutCancel(h.ctx)
+	}
+	spills := [2]*spill{newSpill(ctx, opt, 0), newSpill(ctx, opt, 1)}
+	defer func() {
+		for _, s := range spills {
+			s.close()
 		}
+	}()
 
-		switch {
-		case event.GetData() != nil:
-			data := event.GetData()
+	stalls := 0
+	for {
+		idle := spec.NewIdleTimer(opt.idleTimeout, func() { stream.Close() })
+		for stream.Receive() {
+			// A blocked pipe or callback is not a stall.
+			idle.Stop()
+			stalls = 0
+			event := stream.Event()
+			switch {
+			case event.GetData() != nil:
+				data := event.GetData()
 
-			if data := data.GetStdout(); len(data) > 0 {
-				stdout.WriteString(string(data))
-				if opt.onStdout != nil {
-					opt.onStdout(data)
+				h.mu.Lock()
+				opt, pipes := h.opt, h.pipes
+				h.mu.Unlock()
+
+				if data := data.GetStdout(); len(data) > 0 {
+					spills[0].Write(data)
+					if !writePipe(pipes[0], data) {
+						stdout.Write(data)
+					}
+					if opt.onStdout != nil {
+						opt.onStdout(data)
+					}
 				}
-			}
 
-			if data := data.GetStderr(); len(data) > 0 {
-				stderr.WriteString(string(data))
-				if opt.onStderr != nil {
-					opt.onStderr(data)
+				if data := data.GetStderr(); len(data) > 0 {
+					spills[1].Write(data)
+					if !writePipe(pipes[1], data) {
+						stderr.Write(data)
+					}
+					if opt.onStderr != nil {
+						opt.onStderr(data)
+					}
 				}
-			}
 
-			if pty := data.GetPty(); len(pty) > 0 && opt.onPty != nil {
-				opt.onPty(pty)
-			}
+				if pty := data.GetPty(); len(pty) > 0 {
+					writePipe(pipes[0], pty)
+					if opt.onPty != nil {
+						opt.onPty(pty)
+					}
+				}
 
-		case event.GetEnd() != nil:
-			end := event.GetEnd()
-			result = &CommandResult{
-				Stdout:   stdout.String(),
-				Stderr:   stderr.String(),
-				ExitCode: end.GetExitCode(),
-				Error:    end.GetError(),
+			case event.GetEnd() != nil:
+				h.end()
+				end := event.GetEnd()
+				result = &CommandResult{
+					Stdout:          stdout.String(),
+					Stderr:          stderr.String(),
+					StdoutTruncated: stdout.dropped,
+					StderrTruncated: stderr.dropped,
+					ExitCode:        end.GetExitCode(),
+					Error:           end.GetError(),
+				}
 			}
+			idle.Reset()
+		}
+		idle.Stop()
+
+		if !idle.Stalled() || result != nil || h.timedOut.Load() {
+			break
+		}
+		// Nothing came, not even a keepalive: try a fresh connection.
+		if stalls++; stalls > maxStallReconnects {
+			return nil, fmt.Errorf("process %d: %w", h.pid, ErrStreamStalled)
+		}
+		next, err := h.reattach(opt.idleTimeout)
+		if errors.Is(err, ErrDisconnected) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("process %d: %w: %w", h.pid, ErrStreamStalled, err)
+		}
+		stream = next
+	}
+
+	// The stream may break while the timed out process is stopped.
+	if result == nil && h.timedOut.Load() {
+		result = &CommandResult{
+			Stdout:          stdout.String(),
+			Stderr:          stderr.String(),
+			StdoutTruncated: stdout.dropped,
+			StderrTruncated: stderr.dropped,
+			ExitCode:        -1,
 		}
 	}
 
 	// If Receive stopped, capture any error
-	if err := h.startStream.Err(); err != nil {
+	if err := stream.Err(); err != nil && result == nil {
 		return nil, fmt.Errorf("stream error: %w", err)
 	}
 
 	// If no end event received
 	if result == nil {
-		return nil, errors.New("command ended without end event")
+		return nil, ErrDisconnected
+	}
+
+	h.stdinMu.Lock()
+	if h.stdin != nil {
+		h.stdin.abort()
+	}
+	h.stdinMu.Unlock()
+
+	var spillErr error
+	for i, path := range []*string{&result.StdoutPath, &result.StderrPath} {
+		var err error
+		*path, err = spills[i].close()
+		spillErr = errors.Join(spillErr, err)
 	}
 
+	if h.timedOut.Load() {
+		return nil, &CommandTimeoutError{Timeout: h.timeout, Result: *result}
+	}
 	if result.ExitCode != 0 {
 		return nil, &CommandExitError{Result: *result}
 	}
+	if spillErr != nil {
+		return nil, spillErr
+	}
 
 	return result, nil
 }
 
+// writePipe blocks until data was read from p. It reports whether data was
+// piped, output for a closed pipe is dropped.
+func writePipe(p *outputPipe, data []byte) bool {
+	if p == nil {
+		return false
+	}
+	p.w.Write(data)
+	return true
+}
+
 func (c *CommandHandle) Kill() error {
 	return c.kill(context.Background(), c.pid)
 }
@@ -126,6 +518,13 @@ type CommandResult struct {
 	Stderr   string
 	ExitCode int32
 	Error    string
+
+	// Bytes replaced by the truncation marker, see WithOutputLimit.
+	StdoutTruncated int64
+	StderrTruncated int64
+	// Files holding the complete output, see WithSpill.
+	StdoutPath string
+	StderrPath string
 }
 
 type CommandExitError struct {
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"connectrpc.com/connect"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process"
+	psConnect "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process/processconnect"
+)
+
+// inputWriter sends everything written to it, in order, over one StreamInput
+// call. The call is opened by the first Write or Close.
+type inputWriter struct {
+	mu       sync.Mutex
+	client   psConnect.ProcessClient
+	selector *process.ProcessSelector
+	pty      bool
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stream   *connect.ClientStreamForClient[process.StreamInputRequest, process.StreamInputResponse]
+	err      error
+	closed   bool
+}
+
+func newInputWriter(client psConnect.ProcessClient, selector *process.ProcessSelector, pty bool,
+) *inputWriter {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &inputWriter{
+		client:   client,
+		selector: selector,
+		pty:      pty,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// open sends the start event, w.mu must be held.
+func (w *inputWriter) open() error {
+	if w.stream != nil {
+		return nil
+	}
+
+	w.stream = w.client.StreamInput(w.ctx)
+	err := w.stream.Send(&process.StreamInputRequest{
+		Event: &process.StreamInputRequest_Start{
+			Start: &process.StreamInputRequest_StartEvent{Process: w.selector},
+		},
+	})
+	if err != nil {
+		return w.fail(err)
+	}
+	return nil
+}
+
+// fail ends the stream after a failed Send, w.mu must be held.
+func (w *inputWriter) fail(err error) error {
+	// Send only reports io.EOF, the cause comes with the response.
+	w.closed = true
+	if _, w.err = w.stream.CloseAndReceive(); w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+func (w *inputWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, errors.New("commands: write to closed stdin")
+	}
+	if err := w.open(); err != nil {
+		return 0, err
+	}
+
+	input := &process.ProcessInput{}
+	if w.pty {
+		input.Input = &process.ProcessInput_Pty{Pty: p}
+	} else {
+		input.Input = &process.ProcessInput_Stdin{Stdin: p}
+	}
+
+	err := w.stream.Send(&process.StreamInputRequest{
+		Event: &process.StreamInputRequest_Data{
+			Data: &process.StreamInputRequest_DataEvent{Input: input},
+		},
+	})
+	if err != nil {
+		return 0, w.fail(err)
+	}
+	return len(p), nil
+}
+
+// Close sends the end event, which closes the stdin of the process, and ends
+// the input stream. A pty has no stdin to close.
+func (w *inputWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return w.err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	if !w.pty {
+		err := w.stream.Send(&process.StreamInputRequest{
+			Event: &process.StreamInputRequest_End{
+				End: &process.StreamInputRequest_EndEvent{},
+			},
+		})
+		if err != nil {
+			defer w.cancel()
+			return w.fail(err)
+		}
+	}
+	w.closed = true
+	defer w.cancel()
+	_, w.err = w.stream.CloseAndReceive()
+	return w.err
+}
+
+// abort drops the stream without sending EOF, e.g. once the process exited.
+func (w *inputWriter) abort() {
+	w.cancel()
+}
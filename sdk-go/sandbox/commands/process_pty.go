@@ -2,11 +2,7 @@ package commands
 
 import (
 	"context"
-	"errors"
-	"net"
-	"net/http"
-	"runtime"
-	"time"
+	"fmt"
 
 	"connectrpc.com/connect"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
@@ -18,53 +14,35 @@ type Pty struct {
 	client psConnect.ProcessClient
 }
 
-func NewPty(baseUrl, sandboxID, user string) *Pty {
-	dialer := &net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
-	}
-
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			DialContext:           dialer.DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-			MaxIdleConnsPerHost:   runtime.GOMAXPROCS(0) + 1,
-		},
+func NewPty(baseUrl, sandboxID, user string, opts ...Option) *Pty {
+	opt := newOptions()
+	for _, o := range opts {
+		o(opt)
 	}
 
 	return &Pty{
 		client: psConnect.NewProcessClient(
-			httpClient,
+			opt.httpClient,
 			baseUrl,
 			connect.WithInterceptors(
 				spec.NewHeaderInterceptor(spec.DefaultEnvdPort, sandboxID, user),
+				spec.NewErrorInterceptor(),
 			),
 		),
 	}
 }
 
 func (c *Pty) Kill(ctx context.Context, pid uint32) error {
-	_, err := c.client.SendSignal(ctx, connect.NewRequest(&process.SendSignalRequest{
-		Process: &process.ProcessSelector{
-			Selector: &process.ProcessSelector_Pid{Pid: pid},
-		},
-		Signal: process.Signal_SIGNAL_SIGKILL,
-	}))
-	return err
+	return c.Signal(ctx, ByPid(pid), SIGKILL)
 }
 
 func (c *Pty) SendStdin(ctx context.Context, pid uint32, data []byte) error {
+	return c.SendStdinTo(ctx, ByPid(pid), data)
+}
+
+func (c *Pty) SendStdinTo(ctx context.Context, sel Selector, data []byte) error {
 	_, err := c.client.SendInput(ctx, connect.NewRequest(&process.SendInputRequest{
-		Process: &process.ProcessSelector{
-			Selector: &process.ProcessSelector_Pid{
-				Pid: pid,
-			},
-		},
+		Process: sel.selector,
 		Input: &process.ProcessInput{
 			Input: &process.ProcessInput_Pty{
 				Pty: data,
@@ -104,13 +82,31 @@ func (c *Pty) Create(
 	}
 
 	if !stream.Receive() {
-		return nil, errors.New("failed to start process")
+		return nil, fmt.Errorf("failed to start process: %w", stream.Err())
+	}
+
+	return &CommandHandle{
+		pid:    stream.Msg().Event.GetStart().Pid,
+		kill:   c.Kill,
+		client: c.client,
+		stream: startStream{stream},
+		pty:    true,
+	}, nil
+}
+
+// ConnectTo attaches to a running pty session.
+func (c *Pty) ConnectTo(ctx context.Context, sel Selector) (*CommandHandle, error) {
+	stream, pid, err := attach(ctx, c.client, sel, 0)
+	if err != nil {
+		return nil, err
 	}
 
 	return &CommandHandle{
-		pid:         stream.Msg().Event.GetStart().Pid,
-		kill:        c.Kill,
-		startStream: stream,
+		pid:    pid,
+		kill:   c.Kill,
+		client: c.client,
+		stream: stream,
+		pty:    true,
 	}, nil
 }
 
@@ -0,0 +1,133 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process"
+	psConnect "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process/processconnect"
+)
+
+type Signal = process.Signal
+
+const (
+	SIGHUP  = process.Signal_SIGNAL_SIGHUP
+	SIGINT  = process.Signal_SIGNAL_SIGINT
+	SIGKILL = process.Signal_SIGNAL_SIGKILL
+	SIGUSR1 = process.Signal_SIGNAL_SIGUSR1
+	SIGUSR2 = process.Signal_SIGNAL_SIGUSR2
+	SIGTERM = process.Signal_SIGNAL_SIGTERM
+)
+
+type SignalOption func(*SignalOptions)
+
+type SignalOptions struct {
+	group bool
+}
+
+// WithProcessGroup signals the whole process group led by the process, so
+// children started by a shell are reached too.
+func WithProcessGroup() SignalOption {
+	return func(o *SignalOptions) { o.group = true }
+}
+
+func (c *Cmd) Signal(ctx context.Context, sel Selector, sig Signal, opts ...SignalOption) error {
+	return sendSignal(ctx, c.client, sel, sig, opts...)
+}
+
+// Terminate sends SIGTERM and, if the process is still running after grace,
+// SIGKILL. A process that is already gone is not an error. With
+// WithProcessGroup it always waits grace and then kills what is left of the
+// group, since the leader exiting says nothing about the other members.
+func (c *Cmd) Terminate(ctx context.Context, sel Selector, grace time.Duration,
+	opts ...SignalOption) error {
+	return terminate(ctx, c.client, sel, grace, opts...)
+}
+
+func (c *Pty) Signal(ctx context.Context, sel Selector, sig Signal, opts ...SignalOption) error {
+	return sendSignal(ctx, c.client, sel, sig, opts...)
+}
+
+func (c *Pty) Terminate(ctx context.Context, sel Selector, grace time.Duration,
+	opts ...SignalOption) error {
+	return terminate(ctx, c.client, sel, grace, opts...)
+}
+
+func sendSignal(ctx context.Context, client psConnect.ProcessClient, sel Selector, sig Signal,
+	opts ...SignalOption) error {
+	opt := &SignalOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	_, err := client.SendSignal(ctx, connect.NewRequest(&process.SendSignalRequest{
+		Process: sel.selector,
+		Signal:  sig,
+		Group:   opt.group,
+	}))
+	return err
+}
+
+func terminate(ctx context.Context, client psConnect.ProcessClient, sel Selector, grace time.Duration,
+	opts ...SignalOption) error {
+	opt := &SignalOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	// Subscribe before signalling so the end event cannot be missed.
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, pid, err := attach(streamCtx, client, sel, 0)
+	if err != nil {
+		return ignoreNotFound(err)
+	}
+	// Stick to the pid, a group outlives its leader's tag.
+	sel = ByPid(pid)
+
+	ended := make(chan bool, 1)
+	go func() {
+		defer stream.Close()
+		for stream.Receive() {
+			if stream.Event().GetEnd() != nil {
+				ended <- true
+				return
+			}
+		}
+		ended <- false
+	}()
+
+	if err := sendSignal(ctx, client, sel, SIGTERM, opts...); err != nil {
+		return ignoreNotFound(err)
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case end := <-ended:
+		if end && !opt.group {
+			return nil
+		}
+		// The stream broke or the group may live on, wait out the grace period.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	return ignoreNotFound(sendSignal(ctx, client, sel, SIGKILL, opts...))
+}
+
+func ignoreNotFound(err error) error {
+	if errors.Is(err, spec.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"errors"
+	"time"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/process"
+)
+
+const DefaultShell = "/bin/bash"
+
+// ErrCommandTimeout is what a CommandTimeoutError matches with errors.Is.
+var ErrCommandTimeout = errors.New("command timed out")
+
+// Spec describes a process for Cmd.Exec. Set either Command or Argv.
+type Spec struct {
+	// Command is a command line run by Shell with -c.
+	Command string
+	// Argv runs Argv[0] with the rest as its arguments, without any shell
+	// parsing, so they need no quoting.
+	Argv []string
+
+	// Shell runs Command, DefaultShell if empty.
+	Shell string
+	// Login starts Shell as a login shell, so the profile is sourced first.
+	// With Argv, Shell sources it and then execs Argv.
+	Login bool
+
+	Envs  map[string]string
+	Cwd   string
+	Stdin bool
+	// Tag names the process, it must be unique among running processes.
+	Tag string
+
+	// Timeout stops the process when it is still running after it, counted
+	// from Exec, and Wait returns a *CommandTimeoutError. Zero means no
+	// timeout.
+	Timeout time.Duration
+	// KillGrace is how long the process group has between SIGTERM and
+	// SIGKILL, DefaultKillGrace if zero.
+	KillGrace time.Duration
+	// IdleTimeout reconnects when the stream received neither output nor a
+	// keepalive for this long, and Wait fails with ErrStreamStalled when that
+	// does not help. Keep it above the keepalive interval of envd. Zero
+	// disables it.
+	IdleTimeout time.Duration
+
+	// OnStdout and OnStderr are the defaults for WithStdout and WithStderr
+	// in Wait.
+	OnStdout func([]byte)
+	OnStderr func([]byte)
+}
+
+func (s *Spec) request() (*process.StartRequest, error) {
+	shell := s.Shell
+	if len(shell) == 0 {
+		shell = DefaultShell
+	}
+
+	var cmd string
+	var args []string
+	switch {
+	case len(s.Command) > 0 && len(s.Argv) > 0:
+		return nil, errors.New("commands: spec sets both Command and Argv")
+
+	case len(s.Command) > 0:
+		cmd = shell
+		if s.Login {
+			args = append(args, "-l")
+		}
+		args = append(args, "-c", s.Command)
+
+	case len(s.Argv) > 0 && s.Login:
+		// $0 and $@ are expanded by the shell itself, never re-parsed.
+		cmd = shell
+		args = append([]string{"-l", "-c", `exec "$0" "$@"`}, s.Argv...)
+
+	case len(s.Argv) > 0:
+		cmd = s.Argv[0]
+		args = s.Argv[1:]
+
+	default:
+		return nil, errors.New("commands: spec sets neither Command nor Argv")
+	}
+
+	req := &process.StartRequest{
+		Process: &process.ProcessConfig{
+			Cmd:  cmd,
+			Args: args,
+			Envs: s.Envs,
+			Cwd:  &s.Cwd,
+		},
+		Stdin: &s.Stdin,
+	}
+	if len(s.Tag) > 0 {
+		req.Tag = &s.Tag
+	}
+	return req, nil
+}
@@ -1,14 +1,30 @@
-package commands
+package commands_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/commands"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/sandboxtest"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCmd(t *testing.T) {
-	cmd := NewCmd(
+	cmd := commands.NewCmd(
 		"http://192.168.134.142:48008",
 		"",
 		"root",
@@ -20,18 +36,18 @@ func TestCmd(t *testing.T) {
 }
 
 func TestPty(t *testing.T) {
-	pty := NewPty(
+	pty := commands.NewPty(
 		"http://192.168.134.142:48008",
 		"",
 		"root",
 	)
-	h, err := pty.Create(t.Context(), PtySize{Cols: 80, Rows: 24}, nil, "")
+	h, err := pty.Create(t.Context(), commands.PtySize{Cols: 80, Rows: 24}, nil, "")
 	assert.NoError(t, err)
 
 	err = pty.SendStdin(t.Context(), h.Pid(), []byte("ls -a"))
 	assert.NoError(t, err)
 
-	res, err := h.Wait(t.Context(), WithPty(func(b []byte) { fmt.Println(string(b)) }))
+	res, err := h.Wait(t.Context(), commands.WithPty(func(b []byte) { fmt.Println(string(b)) }))
 	assert.NoError(t, err)
 	fmt.Println(res)
 
@@ -42,3 +58,774 @@ func TestPty(t *testing.T) {
 	assert.NoError(t, err)
 	fmt.Println(res)
 }
+
+func TestCommand(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	out, err := commands.Command(ctx, sbx, "echo", "hello", "$USER").Output()
+	require.NoError(t, err)
+	assert.Equal(t, "hello $USER\n", string(out))
+
+	cmd := commands.Command(ctx, sbx, "sh", "-c", "echo out; echo err >&2; echo $FOO; exit 2")
+	cmd.Env = []string{"FOO=bar"}
+	out, err = cmd.CombinedOutput()
+	var exitErr *commands.CommandExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.EqualValues(t, 2, exitErr.Result.ExitCode)
+	assert.EqualValues(t, 2, cmd.Result.ExitCode)
+	assert.Contains(t, string(out), "out\n")
+	assert.Contains(t, string(out), "err\n")
+	assert.Contains(t, string(out), "bar\n")
+
+	cmd = commands.Command(ctx, sbx, "head", "-n", "2")
+	cmd.Stdin = strings.NewReader("one\ntwo\nthree\n")
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", string(out))
+
+	// cat only exits on EOF.
+	cmd = commands.Command(ctx, sbx, "cat")
+	cmd.Stdin = strings.NewReader("all of it\n")
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "all of it\n", string(out))
+}
+
+func TestCommandKeepsNoOutput(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+
+	// The output only streams through Stdout and Stderr.
+	var stdout, stderr bytes.Buffer
+	cmd := commands.Command(t.Context(), sbx, "sh", "-c", "head -c 2000000 /dev/zero; echo err >&2")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	require.NoError(t, cmd.Run())
+	assert.Equal(t, 2000000, stdout.Len())
+	assert.Equal(t, "err\n", stderr.String())
+	assert.Empty(t, cmd.Result.Stdout)
+	assert.Empty(t, cmd.Result.Stderr)
+	assert.Zero(t, cmd.Result.StdoutTruncated)
+}
+
+func TestCommandPipes(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+
+	cmd := commands.Command(t.Context(), sbx, "sh", "-c", "while read l; do echo got $l; [ $l = q ] && exit; done")
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	assert.NotZero(t, cmd.Pid())
+
+	r := bufio.NewReader(stdout)
+	for _, in := range []string{"a", "b", "q"} {
+		_, err := io.WriteString(stdin, in+"\n")
+		require.NoError(t, err)
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "got "+in+"\n", line)
+	}
+	_, err = r.ReadString('\n')
+	assert.ErrorIs(t, err, io.EOF)
+
+	require.NoError(t, cmd.Wait())
+	assert.Error(t, cmd.Wait())
+}
+
+func TestCommandContext(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 300*time.Millisecond)
+	defer cancel()
+	err := commands.Command(ctx, sbx, "sleep", "30").Run()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.Eventually(t, func() bool {
+		procs, err := sbx.Cmd().List(t.Context())
+		return err == nil && len(procs) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestConnectTo(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Command: "read a; echo first $a; read b; echo second $b >&2; exit 4",
+		Stdin:   true,
+		Tag:     "worker",
+	})
+	require.NoError(t, err)
+	require.NoError(t, h.Disconnect())
+
+	// A fresh handle, as after an SDK restart.
+	h2, err := sbx.Cmd().ConnectTo(ctx, commands.ByTag("worker"))
+	require.NoError(t, err)
+	assert.Equal(t, h.Pid(), h2.Pid())
+
+	require.NoError(t, sbx.Cmd().SendStdin(ctx, h2.Pid(), []byte("x\n")))
+	var first []byte
+	require.NoError(t, sbx.Cmd().SendStdin(ctx, h2.Pid(), []byte("y\n")))
+	_, err = h2.Wait(ctx, commands.WithStdout(func(b []byte) { first = append(first, b...) }))
+	var exitErr *commands.CommandExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.EqualValues(t, 4, exitErr.Result.ExitCode)
+	assert.Equal(t, "first x\n", string(first))
+	assert.Equal(t, "second y\n", exitErr.Result.Stderr)
+
+	_, err = sbx.Cmd().ConnectTo(ctx, commands.ByTag("worker"))
+	assert.ErrorIs(t, err, sandbox.ErrNotFound)
+}
+
+func TestReconnect(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Start(ctx, "read a; echo got $a", nil, "", true)
+	require.NoError(t, err)
+	require.NoError(t, h.Disconnect())
+	_, err = h.Wait(ctx)
+	assert.Error(t, err)
+
+	require.NoError(t, h.Reconnect(ctx))
+	require.NoError(t, sbx.Cmd().SendStdin(ctx, h.Pid(), []byte("z\n")))
+	res, err := h.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "got z\n", res.Stdout)
+}
+
+func TestReconnectPipes(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Start(ctx, "read a; echo got $a", nil, "", true)
+	require.NoError(t, err)
+	stdout := h.Stdout()
+	require.NoError(t, h.Disconnect())
+	_, err = io.ReadAll(stdout)
+	assert.Error(t, err)
+
+	require.NoError(t, h.Reconnect(ctx))
+	stdout = h.Stdout()
+	require.NoError(t, sbx.Cmd().SendStdin(ctx, h.Pid(), []byte("z\n")))
+	out, err := io.ReadAll(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "got z\n", string(out))
+	_, err = h.Wait(ctx)
+	require.NoError(t, err)
+}
+
+func TestStdin(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{Argv: []string{"cat"}, Stdin: true})
+	require.NoError(t, err)
+
+	var want bytes.Buffer
+	stdin := h.Stdin()
+	for i := range 2000 {
+		line := fmt.Sprintf("line %d %s\n", i, bytes.Repeat([]byte("x"), i%100))
+		want.WriteString(line)
+		_, err := io.WriteString(stdin, line)
+		require.NoError(t, err)
+	}
+	require.NoError(t, stdin.Close())
+	_, err = stdin.Write([]byte("late"))
+	assert.Error(t, err)
+
+	res, err := h.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, want.String(), res.Stdout)
+
+	// Closing without writing is an empty input.
+	h, err = sbx.Cmd().Exec(ctx, commands.Spec{Argv: []string{"wc", "-c"}, Stdin: true})
+	require.NoError(t, err)
+	require.NoError(t, h.Stdin().Close())
+	res, err = h.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "0\n", res.Stdout)
+}
+
+func TestOutputPipes(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Command: `for i in $(seq 1 500); do echo "{\"n\":$i}"; done; echo warn >&2`,
+	})
+	require.NoError(t, err)
+
+	dec := json.NewDecoder(h.Stdout())
+	for want := 1; want <= 500; want++ {
+		var msg struct{ N int }
+		require.NoError(t, dec.Decode(&msg))
+		require.Equal(t, want, msg.N)
+	}
+	var rest any
+	assert.ErrorIs(t, dec.Decode(&rest), io.EOF)
+
+	res, err := h.Wait(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, res.Stdout)
+	assert.Equal(t, "warn\n", res.Stderr)
+
+	// A pipe asked for after the process ended is empty.
+	n, err := io.Copy(io.Discard, h.Stderr())
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestOutputBackpressure(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	const size = 64 << 20
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{Argv: []string{"head", "-c", "67108864", "/dev/urandom"}})
+	require.NoError(t, err)
+	stdout := h.Stdout()
+
+	waited := make(chan error, 1)
+	go func() {
+		_, err := h.Wait(ctx)
+		waited <- err
+	}()
+
+	// Nobody reads, so the process cannot finish.
+	time.Sleep(300 * time.Millisecond)
+	procs, err := sbx.Cmd().List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, procs, 1)
+
+	n, err := io.Copy(io.Discard, bufio.NewReader(stdout))
+	require.NoError(t, err)
+	assert.EqualValues(t, size, n)
+	assert.NoError(t, <-waited)
+}
+
+func TestCombinedOutput(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{Command: "echo out; sleep 0.1; echo err >&2; exit 3"})
+	require.NoError(t, err)
+	out, err := h.CombinedOutput(ctx)
+	var exitErr *commands.CommandExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.EqualValues(t, 3, exitErr.Result.ExitCode)
+	assert.Equal(t, "out\nerr\n", string(out))
+}
+
+func TestOutputLimit(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	var full strings.Builder
+	for i := 1; i <= 100000; i++ {
+		fmt.Fprintln(&full, i)
+	}
+
+	res, err := sbx.Cmd().Run(ctx, "seq 1 100000; echo short >&2", nil, "", false,
+		commands.WithOutputLimit(6, 13),
+		commands.WithSpill(sbx.Filesystem(), "logs/seq.out", "logs/seq.err"))
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n3\n\n[... 588876 bytes truncated ...]\n99999\n100000\n", res.Stdout)
+	assert.EqualValues(t, full.Len()-19, res.StdoutTruncated)
+	assert.Equal(t, "short\n", res.Stderr)
+	assert.Zero(t, res.StderrTruncated)
+
+	assert.Equal(t, "logs/seq.out", res.StdoutPath)
+	out, err := sbx.Filesystem().Read(ctx, res.StdoutPath)
+	require.NoError(t, err)
+	assert.Equal(t, full.String(), string(out))
+	out, err = sbx.Filesystem().Read(ctx, res.StderrPath)
+	require.NoError(t, err)
+	assert.Equal(t, "short\n", string(out))
+
+	// No output, no file.
+	res, err = sbx.Cmd().Run(ctx, "true", nil, "", false,
+		commands.WithSpill(sbx.Filesystem(), "logs/true.out", ""))
+	require.NoError(t, err)
+	assert.Empty(t, res.StdoutPath)
+}
+
+// Output arrives in chunks of any size.
+func TestOutputLimitChunks(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	for _, limit := range [][2]int{{0, 0}, {5, 0}, {0, 7}, {16, 16}, {1000, 1000}} {
+		h, err := sbx.Cmd().Exec(ctx, commands.Spec{Command: "cat", Stdin: true})
+		require.NoError(t, err)
+
+		var all bytes.Buffer
+		stdin := h.Stdin()
+		for range 200 {
+			p := make([]byte, 1+rand.IntN(40))
+			for i := range p {
+				p[i] = byte('a' + rand.IntN(26))
+			}
+			all.Write(p)
+			_, err := stdin.Write(p)
+			require.NoError(t, err)
+		}
+		require.NoError(t, stdin.Close())
+
+		res, err := h.Wait(ctx, commands.WithOutputLimit(limit[0], limit[1]))
+		require.NoError(t, err)
+		want := all.String()
+		dropped := len(want) - limit[0] - limit[1]
+		assert.EqualValues(t, dropped, res.StdoutTruncated, "limit %v", limit)
+		assert.True(t, strings.HasPrefix(res.Stdout, want[:limit[0]]), "limit %v", limit)
+		assert.True(t, strings.HasSuffix(res.Stdout, want[len(want)-limit[1]:]), "limit %v", limit)
+		assert.Less(t, len(res.Stdout), len(want), "limit %v", limit)
+	}
+}
+
+func TestSignal(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	cmd := commands.Command(ctx, sbx, "sh", "-c", `trap 'echo usr1' USR1; echo ready; while :; do sleep 0.05; done`)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	r := bufio.NewReader(stdout)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "ready\n", line)
+
+	require.NoError(t, sbx.Cmd().Signal(ctx, commands.ByPid(cmd.Pid()), commands.SIGUSR1))
+	line, err = r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "usr1\n", line)
+
+	require.NoError(t, sbx.Cmd().Terminate(ctx, commands.ByPid(cmd.Pid()), 5*time.Second))
+	cmd.Wait()
+	require.NotNil(t, cmd.Result)
+	assert.EqualValues(t, -1, cmd.Result.ExitCode)
+
+	assert.NoError(t, sbx.Cmd().Terminate(ctx, commands.ByPid(cmd.Pid()), time.Second))
+}
+
+func TestTerminateEscalates(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	// The child ignores SIGTERM and only goes away with the whole group.
+	cmd := commands.Command(ctx, sbx, "sh", "-c", `sh -c 'trap "" TERM; echo $$; exec sleep 30' & wait`)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	child, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	child = strings.TrimSpace(child)
+
+	start := time.Now()
+	require.NoError(t, sbx.Cmd().Terminate(ctx, commands.ByPid(cmd.Pid()), 300*time.Millisecond,
+		commands.WithProcessGroup()))
+	cmd.Wait()
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// Killed is enough, a zombie may wait for a reaper that never comes.
+	out, err := commands.Command(ctx, sbx, "sh", "-c",
+		"state=$(cut -d' ' -f3 /proc/"+child+"/stat 2>/dev/null); [ -z \"$state\" ] || [ $state = Z ] && echo gone").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "gone\n", string(out))
+}
+
+func TestExec(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+
+	prompt := `it's "$(echo pwned)" $HOME`
+	for _, login := range []bool{false, true} {
+		var out []byte
+		h, err := sbx.Cmd().Exec(t.Context(), commands.Spec{
+			Argv:     []string{"printf", "%s", prompt},
+			Login:    login,
+			OnStdout: func(b []byte) { out = append(out, b...) },
+		})
+		require.NoError(t, err)
+		res, err := h.Wait(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, prompt, res.Stdout)
+		assert.Equal(t, prompt, string(out))
+	}
+
+	h, err := sbx.Cmd().Exec(t.Context(), commands.Spec{
+		Command: "echo $0 $FOO",
+		Shell:   "/bin/sh",
+		Envs:    map[string]string{"FOO": "bar"},
+		Tag:     "greeter",
+	})
+	require.NoError(t, err)
+	res, err := h.Wait(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "/bin/sh bar\n", res.Stdout)
+
+	_, err = sbx.Cmd().Exec(t.Context(), commands.Spec{})
+	assert.Error(t, err)
+	_, err = sbx.Cmd().Exec(t.Context(), commands.Spec{Command: "true", Argv: []string{"true"}})
+	assert.Error(t, err)
+}
+
+func TestExecTimeout(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+
+	h, err := sbx.Cmd().Exec(t.Context(), commands.Spec{
+		Argv:    []string{"sleep", "30"},
+		Timeout: 200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = h.Wait(t.Context())
+	assert.ErrorIs(t, err, commands.ErrCommandTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	assert.Eventually(t, func() bool {
+		procs, err := sbx.Cmd().List(t.Context())
+		return err == nil && len(procs) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+// startTimeouts records the deadline sent along with each Start call.
+type startTimeouts struct {
+	mu       sync.Mutex
+	timeouts []string
+}
+
+func (s *startTimeouts) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/process.Process/Start") {
+		s.mu.Lock()
+		s.timeouts = append(s.timeouts, req.Header.Get("Connect-Timeout-Ms"))
+		s.mu.Unlock()
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestExecDeadline(t *testing.T) {
+	rt := &startTimeouts{}
+	sbx := sandboxtest.NewServer(t).NewSandbox(t, sandbox.WithTransport(rt))
+
+	// The deadline stays on the client, envd must not end the stream first.
+	ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+	defer cancel()
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{Argv: []string{"sleep", "30"}, Timeout: time.Minute})
+	require.NoError(t, err)
+
+	_, err = h.Wait(t.Context())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	assert.Equal(t, []string{""}, rt.timeouts)
+}
+
+func TestStallReconnects(t *testing.T) {
+	srv := sandboxtest.NewServer(t, sandboxtest.WithKeepAlive(100*time.Millisecond))
+	sbx := srv.NewSandbox(t)
+	ctx := t.Context()
+
+	started := make(chan struct{})
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Command:     "echo a; sleep 1.5; echo b",
+		IdleTimeout: 500 * time.Millisecond,
+		OnStdout: func(b []byte) {
+			if string(b) == "a\n" {
+				close(started)
+			}
+		},
+	})
+	require.NoError(t, err)
+
+	go func() {
+		<-started
+		srv.StallStreams()
+	}()
+	res, err := h.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", res.Stdout)
+}
+
+func TestStallGivesUp(t *testing.T) {
+	// The default keepalive is far above the idle timeout.
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Argv:        []string{"sleep", "30"},
+		IdleTimeout: 200 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer h.Kill()
+
+	start := time.Now()
+	_, err = h.Wait(ctx)
+	assert.ErrorIs(t, err, commands.ErrStreamStalled)
+	assert.ErrorIs(t, err, sandbox.ErrStreamStalled)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestStallReconnectStalls(t *testing.T) {
+	srv := sandboxtest.NewServer(t)
+	sbx := srv.NewSandbox(t)
+	ctx := t.Context()
+
+	started, err := sbx.Cmd().Exec(ctx, commands.Spec{Argv: []string{"sleep", "30"}, Tag: "sleeper"})
+	require.NoError(t, err)
+	defer started.Kill()
+	h, err := sbx.Cmd().ConnectTo(ctx, commands.ByTag("sleeper"))
+	require.NoError(t, err)
+
+	// Every reconnect stalls too, attaching must not hang.
+	srv.StallNewStreams(true)
+	defer srv.StallNewStreams(false)
+	srv.StallStreams()
+	start := time.Now()
+	_, err = h.Wait(ctx, commands.WithIdleTimeout(200*time.Millisecond))
+	assert.ErrorIs(t, err, commands.ErrStreamStalled)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestStallDisconnect(t *testing.T) {
+	srv := sandboxtest.NewServer(t, sandboxtest.WithKeepAlive(50*time.Millisecond))
+	sbx := srv.NewSandbox(t)
+	ctx := t.Context()
+
+	started, err := sbx.Cmd().Exec(ctx, commands.Spec{Argv: []string{"sleep", "30"}, Tag: "sleeper"})
+	require.NoError(t, err)
+	defer started.Kill()
+	h, err := sbx.Cmd().ConnectTo(ctx, commands.ByTag("sleeper"))
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.Wait(ctx, commands.WithIdleTimeout(200*time.Millisecond))
+		done <- err
+	}()
+
+	// Disconnect must close the stream run reconnected with.
+	srv.StallStreams()
+	time.Sleep(500 * time.Millisecond)
+	require.NoError(t, h.Disconnect())
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after Disconnect")
+	}
+
+	procs, err := sbx.Cmd().List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, procs, 1)
+}
+
+func TestTags(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Command: "read line; echo got $line",
+		Stdin:   true,
+		Tag:     "reader",
+	})
+	require.NoError(t, err)
+	other, err := sbx.Cmd().Start(ctx, "sleep 30", nil, "", false)
+	require.NoError(t, err)
+	defer other.Kill()
+
+	_, err = sbx.Cmd().Exec(ctx, commands.Spec{Command: "true", Tag: "reader"})
+	assert.ErrorIs(t, err, sandbox.ErrConflict)
+
+	procs, err := sbx.Cmd().List(ctx, commands.WithTag("reader"))
+	require.NoError(t, err)
+	require.Len(t, procs, 1)
+	assert.Equal(t, h.Pid(), procs[0].Pid())
+	assert.Equal(t, "reader", procs[0].Tag())
+
+	procs, err = sbx.Cmd().List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, procs, 2)
+
+	require.NoError(t, sbx.Cmd().SendStdinTo(ctx, commands.ByTag("reader"), []byte("hi\n")))
+	res, err := h.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "got hi\n", res.Stdout)
+
+	require.NoError(t, sbx.Cmd().Terminate(ctx, commands.ByTag("missing"), time.Second))
+	_, err = sbx.Cmd().ConnectTo(ctx, commands.ByTag("missing"))
+	assert.ErrorIs(t, err, sandbox.ErrNotFound)
+}
+
+func TestTimeoutGraceful(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Command:   `trap 'echo term; exit 3' TERM; echo start; while :; do sleep 0.05; done`,
+		Timeout:   300 * time.Millisecond,
+		KillGrace: 5 * time.Second,
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = h.Wait(ctx)
+	var timeoutErr *commands.CommandTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.ErrorIs(t, err, commands.ErrCommandTimeout)
+	assert.Less(t, time.Since(start), 3*time.Second)
+	assert.Equal(t, 300*time.Millisecond, timeoutErr.Timeout)
+	assert.Equal(t, "start\nterm\n", timeoutErr.Result.Stdout)
+	assert.EqualValues(t, 3, timeoutErr.Result.ExitCode)
+}
+
+func TestTimeoutKill(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	start := time.Now()
+	_, err := sbx.Cmd().Run(ctx, `trap '' TERM; echo start; sleep 30`, nil, "", false,
+		commands.WithTimeout(200*time.Millisecond),
+		commands.WithKillGrace(300*time.Millisecond))
+	var timeoutErr *commands.CommandTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Greater(t, time.Since(start), 500*time.Millisecond)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, "start\n", timeoutErr.Result.Stdout)
+	assert.EqualValues(t, -1, timeoutErr.Result.ExitCode)
+
+	procs, err := sbx.Cmd().List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, procs)
+}
+
+func TestRunContext(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 300*time.Millisecond)
+	defer cancel()
+	_, err := sbx.Cmd().Run(ctx, "sleep 30", nil, "", false)
+	assert.Error(t, err)
+
+	assert.Eventually(t, func() bool {
+		procs, err := sbx.Cmd().List(t.Context())
+		return err == nil && len(procs) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+type msg struct {
+	N int `json:"n"`
+}
+
+type msgDecoder struct{}
+
+func (msgDecoder) Decode(data []byte) (msg, error) {
+	var m msg
+	err := json.Unmarshal(data, &m)
+	return m, err
+}
+
+func TestStreamFraming(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	// One message split over two writes, several in one write, a banner and
+	// a last line without newline.
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Command: `printf '{"n":'; sleep 0.1; printf '1}\n{"n":2}\r\nbanner\n{"n":3}\n'; printf '{"n":4}'`,
+	})
+	require.NoError(t, err)
+
+	var got []int
+	for m, err := range commands.NewStream(ctx, h, msgDecoder{}).All() {
+		require.NoError(t, err)
+		got = append(got, m.N)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4}, got)
+}
+
+func TestStreamLineTooLong(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Command: `echo '{"n":1}'; printf '{"n":%0100d}\n' 2; sleep 30`,
+	})
+	require.NoError(t, err)
+
+	stream := commands.NewStream(ctx, h, msgDecoder{}, commands.WithMaxLineSize(32))
+	m, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, 1, m.N)
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, commands.ErrLineTooLong)
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, commands.ErrLineTooLong)
+}
+
+// signals counts the signals sent to processes.
+type signals struct {
+	n atomic.Int32
+}
+
+func (s *signals) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/process.Process/SendSignal") {
+		s.n.Add(1)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestStreamLineTooLongKillsOnce(t *testing.T) {
+	rt := &signals{}
+	sbx := sandboxtest.NewServer(t).NewSandbox(t, sandbox.WithTransport(rt))
+	ctx := t.Context()
+
+	// Many chunks arrive after the long line before the kill lands.
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Command: `for i in $(seq 2000); do printf '%0100d' 1; done; sleep 30`,
+	})
+	require.NoError(t, err)
+
+	_, err = commands.NewStream(ctx, h, msgDecoder{}, commands.WithMaxLineSize(32)).Recv()
+	assert.ErrorIs(t, err, commands.ErrLineTooLong)
+	// One kill for the long line, one when the stream ends.
+	assert.LessOrEqual(t, rt.n.Load(), int32(2))
+}
+
+func TestStreamClose(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	ctx := t.Context()
+
+	h, err := sbx.Cmd().Exec(ctx, commands.Spec{
+		Command: `i=0; while :; do i=$((i+1)); echo "{\"n\":$i}"; done`,
+	})
+	require.NoError(t, err)
+
+	stream := commands.NewStream(ctx, h, msgDecoder{})
+	events := stream.Events()
+	ev := <-events
+	require.NoError(t, ev.Err)
+	assert.Equal(t, 1, ev.Value.N)
+
+	// Nobody reads any more, Close must still stop everything.
+	require.NoError(t, stream.Close())
+	assert.Eventually(t, func() bool {
+		procs, err := sbx.Cmd().List(ctx)
+		return err == nil && len(procs) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events not closed")
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultKillGrace is the time between SIGTERM and SIGKILL when a command
+// times out.
+const DefaultKillGrace = 5 * time.Second
+
+// WithTimeout stops the process when it is still running after d, counted
+// from Wait, unless a timeout is already running. See Spec.Timeout.
+func WithTimeout(d time.Duration) HandleOption {
+	return func(ho *HandleOptions) { ho.timeout = d }
+}
+
+// WithKillGrace sets the time between SIGTERM and SIGKILL, see Spec.KillGrace.
+func WithKillGrace(d time.Duration) HandleOption {
+	return func(ho *HandleOptions) { ho.killGrace = d }
+}
+
+// CommandTimeoutError is returned by Wait when the timeout elapsed and the
+// process was stopped. Result holds the output up to then.
+type CommandTimeoutError struct {
+	Timeout time.Duration
+	Result  CommandResult
+}
+
+func (e *CommandTimeoutError) Error() string {
+	return fmt.Sprintf("command timed out after %s", e.Timeout)
+}
+
+func (e *CommandTimeoutError) Unwrap() error {
+	return ErrCommandTimeout
+}
+
+// setTimeout starts the timeout, once per handle.
+func (h *CommandHandle) setTimeout(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.timer == nil {
+		h.timeout = d
+		h.timer = time.AfterFunc(d, h.expire)
+	}
+}
+
+func (h *CommandHandle) expire() {
+	h.timedOut.Store(true)
+	if err := h.stop(); err != nil {
+		// Without the end event Wait would hang, give up on the stream.
+		if h.cancel != nil {
+			h.cancel()
+		} else {
+			h.currentStream().Close()
+		}
+	}
+}
+
+// stop sends SIGTERM to the process group and SIGKILL once the process ended
+// or the grace period is over, for whatever is left of the group.
+func (h *CommandHandle) stop() error {
+	h.mu.Lock()
+	grace := h.opt.killGrace
+	h.mu.Unlock()
+	if grace <= 0 {
+		grace = DefaultKillGrace
+	}
+
+	ctx := context.Background()
+	sel := ByPid(h.pid)
+	if err := sendSignal(ctx, h.client, sel, SIGTERM, WithProcessGroup()); err != nil {
+		return ignoreNotFound(err)
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-h.endedCh():
+	case <-timer.C:
+	}
+	return ignoreNotFound(sendSignal(ctx, h.client, sel, SIGKILL, WithProcessGroup()))
+}
+
+// endedCh is closed once the end event of the process was received.
+func (h *CommandHandle) endedCh() chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.ended == nil {
+		h.ended = make(chan struct{})
+	}
+	return h.ended
+}
+
+// end closes endedCh and stops the timeout.
+func (h *CommandHandle) end() {
+	ended := h.endedCh()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-ended:
+	default:
+		close(ended)
+	}
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+}
@@ -0,0 +1,61 @@
+package sandbox
+
+import (
+	"fmt"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+)
+
+// Sentinel errors matched by ErrorResponse and by the errors of the
+// filesystem and process clients.
+var (
+	ErrNotFound      = spec.ErrNotFound
+	ErrQuotaExceeded = spec.ErrQuotaExceeded
+	ErrRateLimited   = spec.ErrRateLimited
+	ErrNotReady      = spec.ErrNotReady
+	ErrConflict      = spec.ErrConflict
+	ErrUnauthorized  = spec.ErrUnauthorized
+	ErrStreamStalled = spec.ErrStreamStalled
+)
+
+// IsRetryable reports whether an error from any sandbox client is transient.
+func IsRetryable(err error) bool {
+	return spec.IsRetryable(err)
+}
+
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+
+	status int
+}
+
+func (e *ErrorResponse) Error() string {
+	return fmt.Sprintf("Error[%d]: %s", e.Code, e.Message)
+}
+
+// StatusCode returns the HTTP status of the response, falling back to Code
+// when it looks like one.
+func (e *ErrorResponse) StatusCode() int {
+	if e.status > 0 {
+		return e.status
+	}
+	if e.Code >= 100 && e.Code < 600 {
+		return e.Code
+	}
+	return 0
+}
+
+func (e *ErrorResponse) Is(target error) bool {
+	return target != nil && spec.StatusError(e.StatusCode(), e.Message) == target
+}
+
+// setErrorStatus records the HTTP status on every ErrorResponse, including the
+// ones decoded by the template packages through ProxyRequest.
+func setErrorStatus(_ *resty.Client, resp *resty.Response) error {
+	if errResp, ok := resp.Error().(*ErrorResponse); ok && errResp != nil {
+		errResp.status = resp.StatusCode()
+	}
+	return nil
+}
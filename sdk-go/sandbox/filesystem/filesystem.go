@@ -4,10 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net"
-	"net/http"
-	"runtime"
-	"time"
 
 	"connectrpc.com/connect"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
@@ -19,31 +15,19 @@ type Filesystem struct {
 	client fsConnect.FilesystemClient
 }
 
-func NewFileSystem(baseUrl, sandboxID, user string) *Filesystem {
-	dialer := &net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
-	}
-
-	httpClient := &http.Client{
-		Transport: &http.Transport{
-			DialContext:           dialer.DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-			MaxIdleConnsPerHost:   runtime.GOMAXPROCS(0) + 1,
-		},
+func NewFileSystem(baseUrl, sandboxID, user string, opts ...Option) *Filesystem {
+	opt := newOptions()
+	for _, o := range opts {
+		o(opt)
 	}
 
 	return &Filesystem{
 		client: fsConnect.NewFilesystemClient(
-			httpClient,
+			opt.httpClient,
 			baseUrl,
 			connect.WithInterceptors(
 				spec.NewHeaderInterceptor(spec.DefaultEnvdPort, sandboxID, user),
+				spec.NewErrorInterceptor(),
 			),
 		),
 	}
@@ -150,11 +134,8 @@ func (f *Filesystem) Mkdir(ctx context.Context, path string) (bool, error) {
 		connect.NewRequest(&filesystem.MakeDirRequest{Path: path}),
 	)
 	if err != nil {
-		conErr, ok := err.(*connect.Error)
-		if ok {
-			if conErr.Code() == connect.CodeAlreadyExists {
-				return true, nil
-			}
+		if connect.CodeOf(err) == connect.CodeAlreadyExists {
+			return true, nil
 		}
 		return false, err
 	}
@@ -1,14 +1,20 @@
-package filesystem
+package filesystem_test
 
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/filesystem"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/sandboxtest"
+	fspec "github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFilesystem(t *testing.T) {
-	fs := NewFileSystem(
+	fs := filesystem.NewFileSystem(
 		"http://192.168.134.142:48008",
 		"",
 		"root",
@@ -42,3 +48,59 @@ func TestFilesystem(t *testing.T) {
 	assert.NoError(t, err)
 	fmt.Println(buf, n)
 }
+
+func TestWatchDir(t *testing.T) {
+	srv := sandboxtest.NewServer(t, sandboxtest.WithKeepAlive(100*time.Millisecond))
+	sbx := srv.NewSandbox(t)
+	ctx := t.Context()
+	fs := sbx.Filesystem()
+
+	_, err := fs.Mkdir(ctx, "watched")
+	require.NoError(t, err)
+	w, err := fs.WatchDir(ctx, "watched", filesystem.WithIdleTimeout(500*time.Millisecond))
+	require.NoError(t, err)
+	defer w.Close()
+
+	// Keepalives carry the watch over a quiet second.
+	time.AfterFunc(time.Second, func() { fs.Write(ctx, "watched/a", []byte("a")) })
+	event, err := w.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "a", event.GetName())
+	assert.Equal(t, fspec.EventType_EVENT_TYPE_CREATE, event.GetType())
+
+	srv.StallStreams()
+	start := time.Now()
+	for err == nil {
+		_, err = w.Recv()
+	}
+	assert.ErrorIs(t, err, sandbox.ErrStreamStalled)
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestWatchDirSlowConsumer(t *testing.T) {
+	srv := sandboxtest.NewServer(t, sandboxtest.WithKeepAlive(50*time.Millisecond))
+	sbx := srv.NewSandbox(t)
+	ctx := t.Context()
+	fs := sbx.Filesystem()
+
+	_, err := fs.Mkdir(ctx, "watched")
+	require.NoError(t, err)
+	w, err := fs.WatchDir(ctx, "watched", filesystem.WithIdleTimeout(200*time.Millisecond))
+	require.NoError(t, err)
+	defer w.Close()
+
+	for _, name := range []string{"a", "b"} {
+		require.NoError(t, fs.Write(ctx, "watched/"+name, []byte(name)))
+		for {
+			event, err := w.Recv()
+			require.NoError(t, err)
+			if event.GetType() == fspec.EventType_EVENT_TYPE_CREATE {
+				assert.Equal(t, name, event.GetName())
+				break
+			}
+		}
+
+		// Handling the event outlasts the idle timeout.
+		time.Sleep(500 * time.Millisecond)
+	}
+}
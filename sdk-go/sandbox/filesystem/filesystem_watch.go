@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec/filesystem"
+)
+
+type WatchOption func(*WatchOptions)
+
+type WatchOptions struct {
+	recursive   bool
+	idleTimeout time.Duration
+}
+
+func WithRecursive() WatchOption {
+	return func(o *WatchOptions) { o.recursive = true }
+}
+
+// WithIdleTimeout fails the watch with spec.ErrStreamStalled when neither an
+// event nor a keepalive arrived for d. Keep it above the keepalive interval
+// of envd.
+func WithIdleTimeout(d time.Duration) WatchOption {
+	return func(o *WatchOptions) { o.idleTimeout = d }
+}
+
+// WatchHandle streams the changes below a directory.
+type WatchHandle struct {
+	stream *connect.ServerStreamForClient[filesystem.WatchDirResponse]
+	idle   *spec.IdleTimer
+}
+
+// WatchDir watches path until ctx is done or the handle is closed.
+func (f *Filesystem) WatchDir(ctx context.Context, path string, opts ...WatchOption) (*WatchHandle, error) {
+	opt := &WatchOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	stream, err := f.client.WatchDir(ctx, connect.NewRequest(&filesystem.WatchDirRequest{
+		Path:      path,
+		Recursive: opt.recursive,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WatchHandle{stream: stream}
+	w.idle = spec.NewIdleTimer(opt.idleTimeout, func() { stream.Close() })
+
+	// The start event says the watch is in place.
+	if !w.receive() {
+		err := w.err()
+		w.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	return w, nil
+}
+
+// receive runs the idle timer only while it waits: a caller slow to handle an
+// event is not a stall.
+func (w *WatchHandle) receive() bool {
+	w.idle.Reset()
+	ok := w.stream.Receive()
+	w.idle.Stop()
+	return ok
+}
+
+func (w *WatchHandle) err() error {
+	if w.idle.Stalled() {
+		return spec.ErrStreamStalled
+	}
+	if err := w.stream.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// Recv returns the next change, io.EOF once the watch ended. Keepalives are
+// skipped.
+func (w *WatchHandle) Recv() (*filesystem.FilesystemEvent, error) {
+	for w.receive() {
+		if event := w.stream.Msg().GetFilesystem(); event != nil {
+			return event, nil
+		}
+	}
+	return nil, w.err()
+}
+
+func (w *WatchHandle) Close() error {
+	w.idle.Stop()
+	return w.stream.Close()
+}
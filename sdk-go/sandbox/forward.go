@@ -0,0 +1,246 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+	"github.com/sirupsen/logrus"
+)
+
+type ForwardOption func(*ForwardOptions)
+
+type ForwardOptions struct {
+	tcp bool
+}
+
+// WithRawTCP tunnels every connection through the proxy with HTTP CONNECT
+// instead of reverse proxying HTTP, e.g. for database clients. The proxy must
+// support CONNECT.
+func WithRawTCP() ForwardOption {
+	return func(o *ForwardOptions) { o.tcp = true }
+}
+
+// Forwarder serves a local listener that reaches a port inside the sandbox.
+type Forwarder struct {
+	ln     net.Listener
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Addr is the local address the forwarder listens on, useful with ":0".
+func (f *Forwarder) Addr() net.Addr {
+	return f.ln.Addr()
+}
+
+// Close stops listening and waits for the forwarded connections to end.
+func (f *Forwarder) Close() error {
+	f.cancel()
+	err := f.ln.Close()
+	f.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// ForwardPort listens on localAddr and forwards to remotePort inside the
+// sandbox through the proxy, until ctx is done or the forwarder is closed.
+// HTTP, including WebSocket upgrades, is reverse proxied by default.
+func (c *Sandbox) ForwardPort(ctx context.Context, localAddr string, remotePort int,
+	opts ...ForwardOption) (*Forwarder, error) {
+	opt := &ForwardOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	ln, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	f := &Forwarder{ln: ln, cancel: cancel}
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	f.wg.Add(1)
+	if opt.tcp {
+		go c.serveTCP(ctx, f, remotePort)
+	} else {
+		go c.serveHTTP(ctx, f, remotePort)
+	}
+	return f, nil
+}
+
+func (c *Sandbox) serveHTTP(ctx context.Context, f *Forwarder, port int) {
+	defer f.wg.Done()
+
+	srv := &http.Server{
+		Handler:           c.ReverseProxy(port),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	srv.Serve(f.ln)
+}
+
+func (c *Sandbox) serveTCP(ctx context.Context, f *Forwarder, port int) {
+	defer f.wg.Done()
+
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			defer conn.Close()
+
+			if err := c.tunnel(ctx, conn, port); err != nil && ctx.Err() == nil {
+				logrus.WithContext(ctx).Debugf("forward port %d: %v", port, err)
+			}
+		}()
+	}
+}
+
+// tunnel connects local to port through a CONNECT request to the proxy and
+// copies in both directions until either side closes.
+func (c *Sandbox) tunnel(ctx context.Context, local net.Conn, port int) error {
+	remote, err := c.dialProxy(ctx, port)
+	if err != nil {
+		return err
+	}
+	defer remote.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		local.Close()
+		remote.Close()
+	})
+	defer stop()
+
+	errCh := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(remote, local)
+		closeWrite(remote)
+		errCh <- err
+	}()
+	go func() {
+		_, err := io.Copy(local, remote)
+		closeWrite(local)
+		errCh <- err
+	}()
+
+	err = <-errCh
+	if err2 := <-errCh; err == nil {
+		err = err2
+	}
+	return err
+}
+
+// dialProxy opens a connection to port inside the sandbox with HTTP CONNECT.
+func (c *Sandbox) dialProxy(ctx context.Context, port int) (net.Conn, error) {
+	base, err := url.Parse(c.ProxyBaseURL())
+	if err != nil {
+		return nil, err
+	}
+
+	// Dial and handshake like the shared transport does.
+	dial := (&net.Dialer{}).DialContext
+	cfg := &tls.Config{}
+	if tr, ok := c.Transport().(*http.Transport); ok {
+		if tr.DialContext != nil {
+			dial = tr.DialContext
+		}
+		if tr.TLSClientConfig != nil {
+			cfg = tr.TLSClientConfig.Clone()
+		}
+	}
+
+	conn, err := dial(ctx, "tcp", base.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if base.Scheme == "https" {
+		if len(cfg.ServerName) == 0 {
+			cfg.ServerName = base.Hostname()
+		}
+		conn = tls.Client(conn, cfg)
+	}
+
+	// The same headers ReverseProxy sends.
+	headers := spec.GenSandboxHeader(port, c.Name, "")
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: headers["X-HOST"]},
+		Host:   headers["X-HOST"],
+		Header: make(http.Header),
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to port %d: %s", port, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bufferedConn keeps the bytes the proxy sent right after its CONNECT reply.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	return closeWrite(c.Conn)
+}
+
+func closeWrite(conn net.Conn) error {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
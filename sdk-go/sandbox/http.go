@@ -0,0 +1,118 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+	"github.com/mel2oo/go-dkit/otel"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// tracedTransport wraps the sandbox transport with otelhttp.
+func (c *Sandbox) tracedTransport(rt http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(rt,
+		otelhttp.WithTracerProvider(otel.Standard().TracerProvider),
+		otelhttp.WithPropagators(otel.Standard().Propagators),
+		otelhttp.WithSpanNameFormatter(otel.HttpSpanNameFormatter),
+	)
+}
+
+// HTTPClient returns a traced client whose requests reach port inside the
+// sandbox. Send them to ProxyBaseURL.
+func (c *Sandbox) HTTPClient(port int) *http.Client {
+	return &http.Client{
+		Transport: c.tracedTransport(
+			spec.NewHeaderRoundTripper(spec.GenSandboxHeader(port, c.Name, ""), c.Transport()),
+		),
+	}
+}
+
+// WebSocketURL is the ws:// or wss:// URL of path on the proxy, for clients
+// that dial by themselves with the GenSandboxHeader headers.
+func (c *Sandbox) WebSocketURL(path string) string {
+	scheme := "ws"
+	if c.opt.scheme == "https" {
+		scheme = "wss"
+	}
+	return fmt.Sprintf("%s://%s:%d/%s", scheme, c.ProxyHost, c.ProxyPort,
+		strings.TrimLeft(path, "/"))
+}
+
+// WebSocketDial opens a WebSocket to path on port inside the sandbox.
+func (c *Sandbox) WebSocketDial(ctx context.Context, port int, path string,
+) (*websocket.Conn, *http.Response, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: 45 * time.Second}
+	if tr, ok := c.Transport().(*http.Transport); ok {
+		dialer.NetDialContext = tr.DialContext
+		if tr.TLSClientConfig != nil {
+			dialer.TLSClientConfig = tr.TLSClientConfig.Clone()
+		}
+	}
+
+	header := make(http.Header)
+	for k, v := range spec.GenSandboxHeader(port, c.Name, "") {
+		header.Set(k, v)
+	}
+	otel.Standard().Propagators.Inject(ctx, propagation.HeaderCarrier(header))
+
+	return dialer.DialContext(ctx, c.WebSocketURL(path), header)
+}
+
+type ReverseProxyOption func(*ReverseProxyOptions)
+
+type ReverseProxyOptions struct {
+	stripPrefix string
+	rewrite     func(*httputil.ProxyRequest)
+}
+
+// WithStripPrefix removes prefix from the request path, e.g. to serve the app
+// under /apps/<id>/ on our own domain.
+func WithStripPrefix(prefix string) ReverseProxyOption {
+	return func(o *ReverseProxyOptions) { o.stripPrefix = prefix }
+}
+
+// WithRewrite edits each outgoing request after the sandbox headers are set.
+func WithRewrite(fn func(*httputil.ProxyRequest)) ReverseProxyOption {
+	return func(o *ReverseProxyOptions) { o.rewrite = fn }
+}
+
+// ReverseProxy returns a traced handler that forwards requests, including
+// WebSocket upgrades, to port inside the sandbox.
+func (c *Sandbox) ReverseProxy(port int, opts ...ReverseProxyOption) http.Handler {
+	opt := &ReverseProxyOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	target, _ := url.Parse(c.ProxyBaseURL())
+	headers := spec.GenSandboxHeader(port, c.Name, "")
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			if len(opt.stripPrefix) > 0 {
+				r.Out.URL.Path = "/" + strings.TrimLeft(
+					strings.TrimPrefix(r.Out.URL.Path, opt.stripPrefix), "/")
+				r.Out.URL.RawPath = ""
+			}
+
+			r.SetURL(target)
+			r.SetXForwarded()
+			for k, v := range headers {
+				r.Out.Header.Set(k, v)
+			}
+
+			if opt.rewrite != nil {
+				opt.rewrite(r)
+			}
+		},
+		Transport: c.tracedTransport(c.Transport()),
+	}
+}
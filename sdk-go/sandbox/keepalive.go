@@ -0,0 +1,80 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type KeepAliveOption func(*KeepAliveOptions)
+
+type KeepAliveOptions struct {
+	onError func(error)
+}
+
+// WithKeepAliveError reports heartbeat failures. It receives ErrSandboxStopped
+// or ErrSandboxDestroyed right before the keepalive gives up.
+func WithKeepAliveError(fn func(error)) KeepAliveOption {
+	return func(o *KeepAliveOptions) { o.onError = fn }
+}
+
+// KeepAlive sends a heartbeat every interval in the background so the sandbox
+// is not reaped as idle while the caller makes no API calls. It stops when ctx
+// is done or the sandbox is no longer running.
+func (c *Sandbox) KeepAlive(ctx context.Context, interval time.Duration,
+	opts ...KeepAliveOption) {
+	opt := &KeepAliveOptions{}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	report := func(err error) {
+		if opt.onError != nil {
+			opt.onError(err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := c.heartbeat(ctx)
+			if err == nil || ctx.Err() != nil {
+				continue
+			}
+			report(fmt.Errorf("sandbox %s keepalive: %w", c.ID, err))
+
+			res, err := c.GetSandbox(ctx)
+			if errors.Is(err, ErrNotFound) {
+				report(fmt.Errorf("sandbox %s keepalive: %w", c.ID, ErrSandboxDestroyed))
+				return
+			}
+			if err != nil {
+				continue
+			}
+			switch res.State {
+			case StateStopped:
+				report(fmt.Errorf("sandbox %s keepalive: %w", c.ID, ErrSandboxStopped))
+				return
+			case StateDestroyed:
+				report(fmt.Errorf("sandbox %s keepalive: %w", c.ID, ErrSandboxDestroyed))
+				return
+			}
+		}
+	}()
+}
+
+// heartbeat is a cheap request through the proxy to the sandbox agent, which
+// counts as activity for the idle timeout.
+func (c *Sandbox) heartbeat(ctx context.Context) error {
+	_, err := c.Cmd().List(ctx)
+	return err
+}
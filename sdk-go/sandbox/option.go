@@ -1,6 +1,13 @@
 package sandbox
 
-import "fmt"
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+)
 
 var (
 	defaultAPIPort   = 8994
@@ -14,9 +21,17 @@ type Options struct {
 	user        string
 	template    TemplateType
 	sandboxID   string
+	snapshotID  string
+	snapshotDir string
 	apiPort     int
 	proxyPort   int
 	healthPorts []int
+	resources   resources
+
+	retryPolicies map[Operation]RetryPolicy
+
+	httpClient *http.Client
+	scheme     string
 }
 
 func newOptions() *Options {
@@ -26,9 +41,21 @@ func newOptions() *Options {
 		template:  "allinone",
 		apiPort:   defaultAPIPort,
 		proxyPort: defaultProxyPort,
+		scheme:    "http",
 	}
 }
 
+// clone copies o with its own slices and map, so options applied to the copy
+// leave o alone.
+func (o *Options) clone() *Options {
+	c := *o
+	c.healthPorts = slices.Clone(o.healthPorts)
+	c.resources.envs = slices.Clone(o.resources.envs)
+	c.resources.binds = slices.Clone(o.resources.binds)
+	c.retryPolicies = maps.Clone(o.retryPolicies)
+	return &c
+}
+
 func WithHost(host string) Option {
 	return func(o *Options) { o.host = host }
 }
@@ -45,6 +72,36 @@ func WithSandboxID(id string) Option {
 	return func(o *Options) { o.sandboxID = id }
 }
 
+// WithSnapshot creates the sandbox from a snapshot taken by Sandbox.Snapshot.
+func WithSnapshot(id string) Option {
+	return func(o *Options) { o.snapshotID = id }
+}
+
+// WithSnapshotDir sets the local directory used for snapshots when the
+// backend has no native snapshot support. It defaults to os.TempDir().
+func WithSnapshotDir(dir string) Option {
+	return func(o *Options) { o.snapshotDir = dir }
+}
+
+// WithHTTPClient sets the client used for the API, the proxy and the
+// filesystem and process streams. Its transport is shared by all of them.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) { o.httpClient = client }
+}
+
+// WithTransport is WithHTTPClient for a bare transport, e.g. one built by
+// spec.NewTransport with a TLS config or h2c. Pass the same transport to many
+// sandboxes to share its connection pool.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *Options) { o.httpClient = &http.Client{Transport: rt} }
+}
+
+// WithTLS talks https to the API and proxy. The TLS config comes from the
+// transport, see spec.WithTLSConfig.
+func WithTLS() Option {
+	return func(o *Options) { o.scheme = "https" }
+}
+
 func WithAPIPort(port int) Option {
 	return func(o *Options) { o.apiPort = port }
 }
@@ -57,6 +114,41 @@ func WithHealthPorts(ports []int) Option {
 	return func(o *Options) { o.healthPorts = ports }
 }
 
+// WithCPULimit sets the CPU limit, in the unit reported by SandboxDetail.CpuLimit.
+func WithCPULimit(limit int64) Option {
+	return func(o *Options) { o.resources.cpuLimit = limit }
+}
+
+// WithMemoryLimit sets the memory limit in bytes.
+func WithMemoryLimit(limit uint64) Option {
+	return func(o *Options) { o.resources.memLimit = limit }
+}
+
+func WithEnv(key, value string) Option {
+	return func(o *Options) {
+		o.resources.envs = append(o.resources.envs, key+"="+value)
+	}
+}
+
+// WithBind mounts hostPath at sandboxPath, e.g. WithBind("/data", "/mnt/data", true).
+func WithBind(hostPath, sandboxPath string, readOnly bool) Option {
+	return func(o *Options) {
+		bind := hostPath + ":" + sandboxPath
+		if readOnly {
+			bind += ":ro"
+		}
+		o.resources.binds = append(o.resources.binds, bind)
+	}
+}
+
+// WithIdleTimeout sets how long the sandbox may stay idle before it is reaped,
+// rounded up to whole seconds so a sub-second timeout does not disable it.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.resources.timeout = int64((timeout + time.Second - 1) / time.Second)
+	}
+}
+
 type TemplateType string
 
 const (
@@ -67,19 +159,20 @@ const (
 )
 
 type SandboxDetail struct {
-	ID             string   `json:"id"`
-	Name           string   `json:"name"`
-	IP             string   `json:"ip"`
-	User           string   `json:"user"`
-	CreateAt       string   `json:"create_at"`
-	CpuLimit       int64    `json:"cpu_limit"`
-	MemLimit       uint64   `json:"mem_limit"`
-	Envs           []string `json:"envs"`
-	Binds          []string `json:"binds"`
-	Timeout        int64    `json:"timeout"`
-	HealthPorts    []int    `json:"health_ports"`
-	State          string   `json:"state"`
-	LastActionTime int64    `json:"last_action_time"`
+	ID             string       `json:"id"`
+	Name           string       `json:"name"`
+	IP             string       `json:"ip"`
+	User           string       `json:"user"`
+	Template       TemplateType `json:"template,omitempty"`
+	CreateAt       string       `json:"create_at"`
+	CpuLimit       int64        `json:"cpu_limit"`
+	MemLimit       uint64       `json:"mem_limit"`
+	Envs           []string     `json:"envs"`
+	Binds          []string     `json:"binds"`
+	Timeout        int64        `json:"timeout"`
+	HealthPorts    []int        `json:"health_ports"`
+	State          State        `json:"state"`
+	LastActionTime int64        `json:"last_action_time"`
 }
 
 const (
@@ -99,11 +192,16 @@ type InstallDetail struct {
 	RelativePath string `json:"relative_path,omitempty"`
 }
 
-type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message,omitempty"`
-}
-
-func (e *ErrorResponse) Error() string {
-	return fmt.Sprintf("Error[%d]: %s", e.Code, e.Message)
+// client returns the client shared by every sandbox client, by default the
+// one built on spec.DefaultTransport.
+func (o *Options) client() *http.Client {
+	if o.httpClient == nil {
+		return spec.DefaultHTTPClient()
+	}
+	if o.httpClient.Transport == nil {
+		client := *o.httpClient
+		client.Transport = http.DefaultTransport
+		return &client
+	}
+	return o.httpClient
 }
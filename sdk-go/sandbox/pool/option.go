@@ -0,0 +1,72 @@
+package pool
+
+import (
+	"context"
+	"time"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+)
+
+type Option func(*Options)
+
+type Options struct {
+	template       sandbox.TemplateType
+	user           string
+	minIdle        int
+	maxSize        int
+	sandboxOpts    []sandbox.Option
+	refillInterval time.Duration
+	factory        func(context.Context) (*sandbox.Sandbox, error)
+	recycler       func(context.Context, *sandbox.Sandbox) error
+}
+
+func newOptions() *Options {
+	return &Options{
+		template:       sandbox.TemplateAllInOne,
+		user:           "default",
+		minIdle:        1,
+		maxSize:        10,
+		refillInterval: 30 * time.Second,
+	}
+}
+
+func WithTemplate(tmpl sandbox.TemplateType) Option {
+	return func(o *Options) { o.template = tmpl }
+}
+
+func WithUser(id string) Option {
+	return func(o *Options) { o.user = id }
+}
+
+// WithSize sets how many idle sandboxes are kept warm and the upper bound of
+// sandboxes, idle and leased, owned by the pool.
+func WithSize(minIdle, maxSize int) Option {
+	return func(o *Options) {
+		o.minIdle = minIdle
+		o.maxSize = maxSize
+	}
+}
+
+// WithSandboxOptions passes extra options to sandbox.NewSandbox, e.g. the
+// host or health ports.
+func WithSandboxOptions(opts ...sandbox.Option) Option {
+	return func(o *Options) { o.sandboxOpts = append(o.sandboxOpts, opts...) }
+}
+
+// WithRefillInterval sets how often the pool checks its idle size when no
+// lease or release triggered a refill.
+func WithRefillInterval(d time.Duration) Option {
+	return func(o *Options) { o.refillInterval = d }
+}
+
+// WithFactory replaces sandbox.NewSandbox, e.g. to go through codeide.NewSandbox.
+func WithFactory(fn func(context.Context) (*sandbox.Sandbox, error)) Option {
+	return func(o *Options) { o.factory = fn }
+}
+
+// WithRecycler replaces the cleanup applied on Release. By default the
+// processes started during the lease are killed and the home directory is
+// restored to the snapshot taken when the sandbox joined the pool.
+func WithRecycler(fn func(context.Context, *sandbox.Sandbox) error) Option {
+	return func(o *Options) { o.recycler = fn }
+}
@@ -0,0 +1,402 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrPoolClosed  = errors.New("sandbox pool is closed")
+	ErrNotAcquired = errors.New("sandbox was not acquired from this pool")
+)
+
+// Pool keeps pre-created sandboxes of one template and user warm and leases
+// them out.
+type Pool struct {
+	opt *Options
+
+	mu       sync.Mutex
+	idle     []*sandbox.Sandbox
+	leased   map[string]*sandbox.Sandbox
+	creating int
+	closed   bool
+	changed  chan struct{}
+	// baselines is kept per sandbox for the default recycler.
+	baselines map[string]*baseline
+
+	// The home directory the default recycler restores, a snapshot of the
+	// first sandbox taken through snapshotSbx. Empty when it failed. Guarded
+	// by mu.
+	snapshotOnce sync.Once
+	snapshotSbx  *sandbox.Sandbox
+	snapshotID   string
+
+	refill chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	created         atomic.Uint64
+	createFailures  atomic.Uint64
+	destroyed       atomic.Uint64
+	hits            atomic.Uint64
+	misses          atomic.Uint64
+	recycled        atomic.Uint64
+	recycleFailures atomic.Uint64
+}
+
+// Metrics is a point-in-time view of the pool.
+type Metrics struct {
+	Idle     int
+	Leased   int
+	Creating int
+
+	Created         uint64
+	CreateFailures  uint64
+	Destroyed       uint64
+	Hits            uint64
+	Misses          uint64
+	Recycled        uint64
+	RecycleFailures uint64
+}
+
+// New starts a pool and its background refill. The pool lives until Close or
+// until ctx is done.
+func New(ctx context.Context, opts ...Option) (*Pool, error) {
+	opt := newOptions()
+	for _, o := range opts {
+		o(opt)
+	}
+
+	if opt.maxSize <= 0 || opt.minIdle < 0 || opt.minIdle > opt.maxSize {
+		return nil, fmt.Errorf("invalid pool size: min idle %d, max %d",
+			opt.minIdle, opt.maxSize)
+	}
+
+	if opt.factory == nil {
+		sandboxOpts := append(append([]sandbox.Option{}, opt.sandboxOpts...),
+			sandbox.WithTemplate(opt.template),
+			sandbox.WithUser(opt.user),
+		)
+		opt.factory = func(ctx context.Context) (*sandbox.Sandbox, error) {
+			return sandbox.NewSandbox(ctx, sandboxOpts...)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Pool{
+		opt:       opt,
+		leased:    make(map[string]*sandbox.Sandbox),
+		baselines: make(map[string]*baseline),
+		changed:   make(chan struct{}),
+		refill:    make(chan struct{}, 1),
+		cancel:    cancel,
+	}
+
+	p.wg.Add(1)
+	go p.refillLoop(ctx)
+	p.triggerRefill()
+
+	return p, nil
+}
+
+// Acquire leases a sandbox. It takes a warm one if any, creates one if the
+// pool is below its max size, and otherwise waits for a release.
+func (p *Pool) Acquire(ctx context.Context) (*sandbox.Sandbox, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		if n := len(p.idle); n > 0 {
+			sbx := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.leased[sbx.ID] = sbx
+			p.mu.Unlock()
+
+			p.hits.Add(1)
+			p.triggerRefill()
+			return sbx, nil
+		}
+
+		if p.size() < p.opt.maxSize {
+			p.creating++
+			p.mu.Unlock()
+
+			p.misses.Add(1)
+			sbx, err := p.create(ctx)
+
+			p.mu.Lock()
+			p.creating--
+			if err == nil {
+				p.leased[sbx.ID] = sbx
+			}
+			p.broadcast()
+			p.mu.Unlock()
+
+			return sbx, err
+		}
+
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Release hands a leased sandbox back. It is recycled into the idle set, or
+// destroyed when recycling fails or enough sandboxes are already idle.
+func (p *Pool) Release(ctx context.Context, sbx *sandbox.Sandbox) error {
+	if err := p.unlease(sbx); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	keep := !p.closed && len(p.idle) < p.opt.minIdle
+	p.mu.Unlock()
+
+	if keep {
+		if err := p.recycle(ctx, sbx); err != nil {
+			p.recycleFailures.Add(1)
+			logrus.WithContext(ctx).
+				Warnf("recycle sandbox %s failed, destroying it: %v", sbx.ID, err)
+		} else {
+			p.recycled.Add(1)
+
+			p.mu.Lock()
+			if !p.closed {
+				p.idle = append(p.idle, sbx)
+				p.broadcast()
+				p.mu.Unlock()
+				return nil
+			}
+			p.mu.Unlock()
+		}
+	}
+
+	err := p.destroy(ctx, sbx)
+	p.triggerRefill()
+	return err
+}
+
+// Discard destroys a leased sandbox without trying to recycle it.
+func (p *Pool) Discard(ctx context.Context, sbx *sandbox.Sandbox) error {
+	if err := p.unlease(sbx); err != nil {
+		return err
+	}
+
+	err := p.destroy(ctx, sbx)
+	p.triggerRefill()
+	return err
+}
+
+// Close stops the refill and destroys all idle sandboxes. Leased sandboxes
+// stay with their holders and are destroyed when released.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.broadcast()
+	p.mu.Unlock()
+
+	p.cancel()
+	p.wg.Wait()
+
+	var errs []error
+	for _, sbx := range idle {
+		if err := p.destroy(ctx, sbx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Leased sandboxes are destroyed on release, nobody restores it any more.
+	p.mu.Lock()
+	snapshotSbx, snapshotID := p.snapshotSbx, p.snapshotID
+	p.mu.Unlock()
+	if len(snapshotID) > 0 {
+		if err := snapshotSbx.DeleteSnapshot(ctx, snapshotID); err != nil {
+			errs = append(errs, fmt.Errorf("delete snapshot %s: %w", snapshotID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Pool) Metrics() Metrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return Metrics{
+		Idle:            len(p.idle),
+		Leased:          len(p.leased),
+		Creating:        p.creating,
+		Created:         p.created.Load(),
+		CreateFailures:  p.createFailures.Load(),
+		Destroyed:       p.destroyed.Load(),
+		Hits:            p.hits.Load(),
+		Misses:          p.misses.Load(),
+		Recycled:        p.recycled.Load(),
+		RecycleFailures: p.recycleFailures.Load(),
+	}
+}
+
+func (p *Pool) unlease(sbx *sandbox.Sandbox) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.leased[sbx.ID]; !ok {
+		return ErrNotAcquired
+	}
+	delete(p.leased, sbx.ID)
+	p.broadcast()
+	return nil
+}
+
+func (p *Pool) refillLoop(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.opt.refillInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.refill:
+		}
+
+		for p.reserve() {
+			sbx, err := p.create(ctx)
+
+			p.mu.Lock()
+			p.creating--
+			if err == nil {
+				if p.closed {
+					p.mu.Unlock()
+					p.destroy(context.Background(), sbx)
+					return
+				}
+				p.idle = append(p.idle, sbx)
+			}
+			p.broadcast()
+			p.mu.Unlock()
+
+			if err != nil {
+				logrus.WithContext(ctx).Warnf("refill sandbox pool: %v", err)
+				break
+			}
+		}
+	}
+}
+
+// reserve claims a creation slot when the idle set is below its minimum.
+func (p *Pool) reserve() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed ||
+		len(p.idle)+p.creating >= p.opt.minIdle ||
+		p.size() >= p.opt.maxSize {
+		return false
+	}
+	p.creating++
+	return true
+}
+
+func (p *Pool) create(ctx context.Context) (*sandbox.Sandbox, error) {
+	sbx, err := p.opt.factory(ctx)
+	if err != nil {
+		p.createFailures.Add(1)
+		return nil, err
+	}
+
+	if p.opt.recycler == nil {
+		b, err := newBaseline(ctx, sbx)
+		if err != nil {
+			p.createFailures.Add(1)
+			sbx.DestroySandbox(context.WithoutCancel(ctx))
+			return nil, fmt.Errorf("sandbox %s baseline: %w", sbx.ID, err)
+		}
+		p.snapshotOnce.Do(func() { p.snapshot(ctx, sbx) })
+
+		p.mu.Lock()
+		p.baselines[sbx.ID] = b
+		p.mu.Unlock()
+	}
+
+	p.created.Add(1)
+	return sbx, nil
+}
+
+func (p *Pool) recycle(ctx context.Context, sbx *sandbox.Sandbox) error {
+	if p.opt.recycler != nil {
+		return p.opt.recycler(ctx, sbx)
+	}
+
+	p.mu.Lock()
+	b, snapshotID := p.baselines[sbx.ID], p.snapshotID
+	p.mu.Unlock()
+	return b.reset(ctx, sbx, snapshotID)
+}
+
+// snapshot takes the snapshot of a fresh sandbox the default recycler restores.
+// Without it the home directory is emptied instead.
+func (p *Pool) snapshot(ctx context.Context, sbx *sandbox.Sandbox) {
+	snapshot, err := sbx.Snapshot(ctx, "pool-"+sbx.ID)
+	if err != nil {
+		logrus.WithContext(ctx).
+			Warnf("snapshot sandbox %s failed, recycling empties the home dir: %v", sbx.ID, err)
+		return
+	}
+	p.mu.Lock()
+	p.snapshotSbx, p.snapshotID = sbx, snapshot.ID
+	p.mu.Unlock()
+}
+
+func (p *Pool) destroy(ctx context.Context, sbx *sandbox.Sandbox) error {
+	if err := sbx.DestroySandbox(ctx); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	delete(p.baselines, sbx.ID)
+	p.mu.Unlock()
+
+	p.destroyed.Add(1)
+	return nil
+}
+
+// size must be called with p.mu held.
+func (p *Pool) size() int {
+	return len(p.idle) + len(p.leased) + p.creating
+}
+
+// broadcast wakes up every Acquire waiting for capacity. It must be called
+// with p.mu held.
+func (p *Pool) broadcast() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+func (p *Pool) triggerRefill() {
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,207 @@
+package pool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/commands"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/sandboxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeAPI(t *testing.T) ([]sandbox.Option, *atomic.Int64) {
+	var seq, destroyed atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/secvirt/v2/sandboxes":
+			id := fmt.Sprintf("sbx-%d", seq.Add(1))
+			json.NewEncoder(w).Encode(sandbox.SandboxDetail{ID: id, Name: id, State: "running"})
+		case strings.HasSuffix(r.URL.Path, "/destroy"):
+			destroyed.Add(1)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	return []sandbox.Option{
+		sandbox.WithHost(u.Hostname()),
+		sandbox.WithAPIPort(port),
+		sandbox.WithProxyPort(port),
+	}, &destroyed
+}
+
+func noopRecycler(context.Context, *sandbox.Sandbox) error { return nil }
+
+func TestPoolAcquireRelease(t *testing.T) {
+	opts, destroyed := newFakeAPI(t)
+
+	p, err := New(t.Context(),
+		WithSize(2, 3),
+		WithSandboxOptions(opts...),
+		WithRecycler(noopRecycler),
+	)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return p.Metrics().Idle == 2 },
+		time.Second, 10*time.Millisecond)
+
+	sbx1, err := p.Acquire(t.Context())
+	require.NoError(t, err)
+	sbx2, err := p.Acquire(t.Context())
+	require.NoError(t, err)
+	sbx3, err := p.Acquire(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), p.Metrics().Hits)
+
+	// The pool is full, so the next lease waits for a release.
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	_, err = p.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	done := make(chan *sandbox.Sandbox)
+	go func() {
+		sbx, _ := p.Acquire(t.Context())
+		done <- sbx
+	}()
+	require.NoError(t, p.Release(t.Context(), sbx1))
+	assert.Equal(t, sbx1.ID, (<-done).ID)
+
+	require.NoError(t, p.Discard(t.Context(), sbx2))
+	assert.Equal(t, int64(1), destroyed.Load())
+	assert.ErrorIs(t, p.Release(t.Context(), sbx2), ErrNotAcquired)
+
+	require.NoError(t, p.Release(t.Context(), sbx3))
+	require.NoError(t, p.Close(t.Context()))
+
+	_, err = p.Acquire(t.Context())
+	assert.ErrorIs(t, err, ErrPoolClosed)
+}
+
+func TestPoolRecycleFailureDestroys(t *testing.T) {
+	opts, destroyed := newFakeAPI(t)
+
+	p, err := New(t.Context(),
+		WithSize(1, 1),
+		WithSandboxOptions(opts...),
+		WithRecycler(func(context.Context, *sandbox.Sandbox) error {
+			return errors.New("reset failed")
+		}),
+	)
+	require.NoError(t, err)
+	defer p.Close(t.Context())
+
+	sbx, err := p.Acquire(t.Context())
+	require.NoError(t, err)
+	require.NoError(t, p.Release(t.Context(), sbx))
+
+	m := p.Metrics()
+	assert.Equal(t, uint64(1), m.RecycleFailures)
+	assert.Equal(t, int64(1), destroyed.Load())
+}
+
+func TestPoolReset(t *testing.T) {
+	srv := sandboxtest.NewServer(t)
+	p, err := New(t.Context(),
+		WithSize(2, 2),
+		WithFactory(templateFactory(srv.Options()...)),
+	)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return p.Metrics().Idle == 2 },
+		5*time.Second, 10*time.Millisecond)
+	// One snapshot for the pool, not one per sandbox.
+	assert.Len(t, srv.Snapshots(), 1)
+
+	sbx, err := p.Acquire(t.Context())
+	require.NoError(t, err)
+	require.NoError(t, sbx.Filesystem().Write(t.Context(), ".profile", []byte("lease")))
+	require.NoError(t, sbx.Filesystem().Write(t.Context(), "junk", []byte("lease")))
+	child := startWithChild(t, sbx)
+	require.NoError(t, p.Release(t.Context(), sbx))
+	assert.Equal(t, uint64(1), p.Metrics().Recycled)
+
+	data, err := sbx.Filesystem().Read(t.Context(), ".profile")
+	require.NoError(t, err)
+	assert.Equal(t, "template", string(data))
+	_, err = sbx.Filesystem().Read(t.Context(), "junk")
+	assert.ErrorIs(t, err, sandbox.ErrNotFound)
+
+	procs, err := sbx.Cmd().List(t.Context())
+	require.NoError(t, err)
+	require.Len(t, procs, 1)
+	assert.Equal(t, "daemon", procs[0].Tag())
+	assert.Eventually(t, func() bool { return syscall.Kill(child, 0) != nil },
+		5*time.Second, 10*time.Millisecond, "the lease's background job survived")
+
+	require.NoError(t, p.Close(t.Context()))
+	assert.Empty(t, srv.Snapshots())
+}
+
+func TestPoolResetWithoutSnapshot(t *testing.T) {
+	// The snapshot cannot be written, recycling empties the home dir.
+	opts := append(sandboxtest.New(t, sandboxtest.WithoutSnapshots(http.StatusNotImplemented)),
+		sandbox.WithSnapshotDir(filepath.Join(t.TempDir(), "missing")))
+	p, err := New(t.Context(), WithSize(1, 1), WithFactory(templateFactory(opts...)))
+	require.NoError(t, err)
+	defer p.Close(t.Context())
+
+	sbx, err := p.Acquire(t.Context())
+	require.NoError(t, err)
+	require.NoError(t, sbx.Filesystem().Write(t.Context(), "junk", []byte("lease")))
+	require.NoError(t, p.Release(t.Context(), sbx))
+	assert.Equal(t, uint64(1), p.Metrics().Recycled)
+
+	_, err = sbx.Filesystem().Read(t.Context(), "junk")
+	assert.ErrorIs(t, err, sandbox.ErrNotFound)
+}
+
+// templateFactory creates sandboxes with what a template brings along: a
+// dotfile and a tagged daemon.
+func templateFactory(opts ...sandbox.Option) func(context.Context) (*sandbox.Sandbox, error) {
+	return func(ctx context.Context) (*sandbox.Sandbox, error) {
+		sbx, err := sandbox.NewSandbox(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if err := sbx.Filesystem().Write(ctx, ".profile", []byte("template")); err != nil {
+			return nil, err
+		}
+		_, err = sbx.Cmd().Exec(ctx, commands.Spec{Argv: []string{"sleep", "30"}, Tag: "daemon"})
+		return sbx, err
+	}
+}
+
+// startWithChild starts a shell with a background job and returns the host
+// pid of the job.
+func startWithChild(t *testing.T, sbx *sandbox.Sandbox) int {
+	h, err := sbx.Cmd().Exec(t.Context(), commands.Spec{Command: "sleep 30 & echo $!; wait"})
+	require.NoError(t, err)
+	line, err := bufio.NewReader(h.Stdout()).ReadString('\n')
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(line))
+	require.NoError(t, err)
+	return pid
+}
@@ -0,0 +1,76 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/commands"
+)
+
+// baseline is the processes a sandbox ran when it joined the pool, those the
+// template started.
+type baseline struct {
+	tags map[string]bool
+	pids map[uint32]bool
+}
+
+func newBaseline(ctx context.Context, sbx *sandbox.Sandbox) (*baseline, error) {
+	procs, err := sbx.Cmd().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list processes: %w", err)
+	}
+
+	b := &baseline{
+		tags: make(map[string]bool),
+		pids: make(map[uint32]bool),
+	}
+	for _, proc := range procs {
+		if len(proc.Tag()) > 0 {
+			b.tags[proc.Tag()] = true
+		} else {
+			b.pids[proc.Pid()] = true
+		}
+	}
+	return b, nil
+}
+
+// reset is the default recycler. It kills the process groups started during
+// the lease and restores the home directory from snapshotID, or empties it
+// when the pool has no snapshot. A tagged process is kept when its tag was
+// running at baseline, so a restarted daemon survives.
+func (b *baseline) reset(ctx context.Context, sbx *sandbox.Sandbox, snapshotID string) error {
+	procs, err := sbx.Cmd().List(ctx)
+	if err != nil {
+		return fmt.Errorf("list processes: %w", err)
+	}
+
+	var errs []error
+	for _, proc := range procs {
+		if b.tags[proc.Tag()] || (len(proc.Tag()) == 0 && b.pids[proc.Pid()]) {
+			continue
+		}
+		if err := sbx.Cmd().Signal(ctx, commands.ByPid(proc.Pid()), commands.SIGKILL,
+			commands.WithProcessGroup()); err != nil && !errors.Is(err, sandbox.ErrNotFound) {
+			errs = append(errs, fmt.Errorf("kill process %d: %w", proc.Pid(), err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if len(snapshotID) > 0 {
+		if err := sbx.RestoreSandbox(ctx, snapshotID); err != nil {
+			return fmt.Errorf("restore home dir: %w", err)
+		}
+		return nil
+	}
+
+	cmd := commands.Command(ctx, sbx, "find", ".", "-mindepth", "1", "-delete")
+	cmd.Dir = sbx.HomeDir()
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("reset home dir: %w: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrResourceMismatch = errors.New("sandbox does not match requested resources")
+
+type resources struct {
+	cpuLimit int64
+	memLimit uint64
+	envs     []string
+	binds    []string
+	timeout  int64
+}
+
+func (r resources) apply(body map[string]any) {
+	if r.cpuLimit > 0 {
+		body["cpu_limit"] = r.cpuLimit
+	}
+	if r.memLimit > 0 {
+		body["mem_limit"] = r.memLimit
+	}
+	if len(r.envs) > 0 {
+		body["envs"] = r.envs
+	}
+	if len(r.binds) > 0 {
+		body["binds"] = r.binds
+	}
+	if r.timeout > 0 {
+		body["timeout"] = r.timeout
+	}
+}
+
+// check compares the requested resources with what the backend reports, so a
+// backend that silently drops a setting is caught at creation time.
+func (r resources) check(res *SandboxDetail) error {
+	if r.cpuLimit > 0 && res.CpuLimit != r.cpuLimit {
+		return fmt.Errorf("%w: cpu limit %d, got %d", ErrResourceMismatch, r.cpuLimit, res.CpuLimit)
+	}
+	if r.memLimit > 0 && res.MemLimit != r.memLimit {
+		return fmt.Errorf("%w: memory limit %d, got %d", ErrResourceMismatch, r.memLimit, res.MemLimit)
+	}
+	for _, env := range r.envs {
+		if !slices.Contains(res.Envs, env) {
+			return fmt.Errorf("%w: env %s missing", ErrResourceMismatch, env)
+		}
+	}
+	for _, bind := range r.binds {
+		if !slices.ContainsFunc(res.Binds, func(b string) bool {
+			return b == bind || strings.HasPrefix(b, bind+":")
+		}) {
+			return fmt.Errorf("%w: bind %s missing", ErrResourceMismatch, bind)
+		}
+	}
+	if r.timeout > 0 && res.Timeout != r.timeout {
+		return fmt.Errorf("%w: timeout %d, got %d", ErrResourceMismatch, r.timeout, res.Timeout)
+	}
+	return nil
+}
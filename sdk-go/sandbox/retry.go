@@ -0,0 +1,62 @@
+package sandbox
+
+import "time"
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Operation names an API call for WithRetryPolicy.
+type Operation string
+
+const (
+	OpCreate         Operation = "create"
+	OpGet            Operation = "get"
+	OpList           Operation = "list"
+	OpStart          Operation = "start"
+	OpStop           Operation = "stop"
+	OpDestroy        Operation = "destroy"
+	OpPackageInstall Operation = "package_install"
+	OpSnapshot       Operation = "snapshot"
+	OpRestore        Operation = "restore"
+	OpDeleteSnapshot Operation = "delete_snapshot"
+	OpProxy          Operation = "proxy"
+)
+
+func (op Operation) mutating() bool {
+	switch op {
+	case OpGet, OpList, OpProxy:
+		return false
+	}
+	return true
+}
+
+type RetryPolicy struct {
+	// Count is the number of retries after the first attempt. 0 disables
+	// retries.
+	Count       int
+	WaitTime    time.Duration
+	MaxWaitTime time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Count:       6,
+	WaitTime:    500 * time.Millisecond,
+	MaxWaitTime: 3 * time.Second,
+}
+
+// WithRetryPolicy overrides DefaultRetryPolicy for one operation. OpProxy
+// applies to requests built by ProxyRequest.
+func WithRetryPolicy(op Operation, policy RetryPolicy) Option {
+	return func(o *Options) {
+		if o.retryPolicies == nil {
+			o.retryPolicies = make(map[Operation]RetryPolicy)
+		}
+		o.retryPolicies[op] = policy
+	}
+}
+
+func (o *Options) retryPolicy(op Operation) RetryPolicy {
+	if policy, ok := o.retryPolicies[op]; ok {
+		return policy
+	}
+	return DefaultRetryPolicy
+}
@@ -3,25 +3,24 @@ package sandbox
 import (
 	"context"
 	"fmt"
-	"time"
+	"net/http"
 
-	"github.com/dubonzi/otelresty"
 	"github.com/go-resty/resty/v2"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/commands"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/filesystem"
 	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
 	"github.com/mel2oo/go-dkit/ext"
-	"github.com/mel2oo/go-dkit/otel"
 )
 
 type Sandbox struct {
 	*SandboxDetail
 
-	api   *resty.Client // API客户端
-	proxy *resty.Client // 沙箱代理客户端
-	fs    *filesystem.Filesystem
-	cmd   *commands.Cmd
-	pty   *commands.Pty
+	client *Client
+	proxy  *resty.Client // 沙箱代理客户端
+	fs     *filesystem.Filesystem
+	cmd    *commands.Cmd
+	pty    *commands.Pty
+	opt    *Options
 
 	ProxyHost string
 	ProxyPort int
@@ -33,77 +32,100 @@ func NewSandbox(ctx context.Context, opts ...Option) (*Sandbox, error) {
 		o(opt)
 	}
 
-	apiBaseUrl := fmt.Sprintf("http://%s:%d", opt.host, opt.apiPort)
-	apiClient := resty.New()
-	apiClient.SetRetryCount(6)
-	apiClient.SetRetryWaitTime(time.Millisecond * 500)
-	apiClient.SetRetryMaxWaitTime(time.Second * 3)
-	apiClient.AddRetryCondition(func(r *resty.Response, err error) bool {
-		if err != nil {
-			return true
-		}
+	if len(opt.sandboxID) > 0 {
+		return attach(ctx, opt)
+	}
+
+	sbx := newSandbox(opt)
+	res, err := sbx._createSandbox(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	sbx.bind(res)
+	if err := opt.resources.check(res); err != nil {
+		sbx.DestroySandbox(context.WithoutCancel(ctx))
+		return nil, err
+	}
 
-		return r.StatusCode() >= 500 || r.StatusCode() == 429
-	})
-	apiClient.SetBaseURL(apiBaseUrl)
-	otelresty.TraceClient(apiClient,
-		otelresty.WithSpanNameFormatter(otel.RestySpanNameFormatter),
-		otelresty.WithTracerProvider(otel.Standard().TracerProvider),
-		otelresty.WithPropagators(otel.Standard().Propagators),
-	)
-
-	prxBaseUrl := fmt.Sprintf("http://%s:%d", opt.host, opt.proxyPort)
-	prxClient := resty.New()
-	prxClient.SetRetryCount(6)
-	prxClient.SetRetryWaitTime(time.Millisecond * 500)
-	prxClient.SetRetryMaxWaitTime(time.Second * 3)
-	prxClient.AddRetryCondition(func(r *resty.Response, err error) bool {
-		if err != nil {
-			return true
+	if isLocalSnapshot(opt.snapshotID) {
+		if err := sbx.restoreLocal(ctx, opt.snapshotID); err != nil {
+			sbx.DestroySandbox(context.WithoutCancel(ctx))
+			return nil, err
 		}
+	}
 
-		return r.StatusCode() >= 500 || r.StatusCode() == 429
-	})
-	prxClient.SetBaseURL(prxBaseUrl)
-	otelresty.TraceClient(prxClient,
-		otelresty.WithSpanNameFormatter(otel.RestySpanNameFormatter),
-		otelresty.WithTracerProvider(otel.Standard().TracerProvider),
-		otelresty.WithPropagators(otel.Standard().Propagators),
-	)
-
-	sbx := &Sandbox{
-		api:       apiClient,
-		proxy:     prxClient,
-		ProxyHost: opt.host,
-		ProxyPort: opt.proxyPort,
+	return sbx, nil
+}
+
+// Attach connects to an existing sandbox instead of creating a new one.
+func Attach(ctx context.Context, id string, opts ...Option) (*Sandbox, error) {
+	opt := newOptions()
+	for _, o := range opts {
+		o(opt)
 	}
+	opt.sandboxID = id
+
+	return attach(ctx, opt)
+}
 
-	res, err := sbx._createSandbox(
-		ctx,
-		opt.user,
-		opt.template,
-		opt.healthPorts,
-	)
+func attach(ctx context.Context, opt *Options) (*Sandbox, error) {
+	sbx := newSandbox(opt)
+	sbx.SandboxDetail = &SandboxDetail{ID: opt.sandboxID}
+
+	res, err := sbx.GetSandbox(ctx)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("attach sandbox %s: %w", opt.sandboxID, err)
 	}
 
-	sbx.fs = filesystem.NewFileSystem(prxBaseUrl, res.Name, res.User)
-	sbx.cmd = commands.NewCmd(prxBaseUrl, res.Name, res.User)
-	sbx.pty = commands.NewPty(prxBaseUrl, res.Name, res.User)
-	sbx.SandboxDetail = res
+	switch res.State {
+	case StateStopped:
+		return nil, fmt.Errorf("attach sandbox %s: %w", opt.sandboxID, ErrSandboxStopped)
+	case StateDestroyed:
+		return nil, fmt.Errorf("attach sandbox %s: %w", opt.sandboxID, ErrSandboxDestroyed)
+	}
+
+	sbx.bind(res)
 	return sbx, nil
 }
 
-func (c *Sandbox) _createSandbox(ctx context.Context, userID string,
-	template TemplateType, healthPorts []int) (*SandboxDetail, error) {
-	resp, err := c.ApiRequest(ctx).
+func newSandbox(opt *Options) *Sandbox {
+	client := newClient(opt)
+	return &Sandbox{
+		client:    client,
+		proxy:     client.proxy,
+		opt:       opt,
+		ProxyHost: opt.host,
+		ProxyPort: opt.proxyPort,
+	}
+}
+
+func (c *Sandbox) bind(res *SandboxDetail) {
+	prxBaseUrl := c.ProxyBaseURL()
+	httpClient := c.client.http
+	c.fs = filesystem.NewFileSystem(prxBaseUrl, res.Name, res.User,
+		filesystem.WithHTTPClient(httpClient))
+	c.cmd = commands.NewCmd(prxBaseUrl, res.Name, res.User,
+		commands.WithHTTPClient(httpClient))
+	c.pty = commands.NewPty(prxBaseUrl, res.Name, res.User,
+		commands.WithHTTPClient(httpClient))
+	c.SandboxDetail = res
+}
+
+func (c *Sandbox) _createSandbox(ctx context.Context, opt *Options) (*SandboxDetail, error) {
+	body := map[string]any{
+		"user_id":      opt.user,
+		"template":     opt.template,
+		"health_ports": opt.healthPorts,
+	}
+	opt.resources.apply(body)
+	if len(opt.snapshotID) > 0 && !isLocalSnapshot(opt.snapshotID) {
+		body["snapshot_id"] = opt.snapshotID
+	}
+
+	resp, err := c.client.request(ctx, OpCreate).
 		SetContext(ctx).
-		SetBody(map[string]any{
-			"user_id":      userID,
-			"template":     template,
-			"health_ports": healthPorts,
-		}).
+		SetBody(body).
 		SetResult(SandboxDetail{}).
 		SetError(ErrorResponse{}).
 		Post("/secvirt/v2/sandboxes")
@@ -129,11 +151,14 @@ func (c *Sandbox) ProxyRequest(ctx context.Context, port int) *resty.Request {
 	return req
 }
 
+// Transport is the round tripper shared by all clients of the sandbox. Build
+// other clients that talk through the proxy on top of it.
+func (c *Sandbox) Transport() http.RoundTripper {
+	return c.client.http.Transport
+}
+
 func (c *Sandbox) ApiRequest(ctx context.Context) *resty.Request {
-	req := c.api.R()
-	req.SetContext(ctx)
-	ext.InjectHeader(ctx, req.Header)
-	return req
+	return c.client.ApiRequest(ctx)
 }
 
 func (c *Sandbox) HomeDir() string {
@@ -153,7 +178,7 @@ func (c *Sandbox) Pty() *commands.Pty {
 }
 
 func (c *Sandbox) GetSandbox(ctx context.Context) (*SandboxDetail, error) {
-	resp, err := c.ApiRequest(ctx).
+	resp, err := c.client.request(ctx, OpGet).
 		SetContext(ctx).
 		SetResult(SandboxDetail{}).
 		SetError(ErrorResponse{}).
@@ -168,8 +193,21 @@ func (c *Sandbox) GetSandbox(ctx context.Context) (*SandboxDetail, error) {
 	return resp.Result().(*SandboxDetail), nil
 }
 
-func (c *Sandbox) StopSandbox(ctx context.Context) error {
-	resp, err := c.ApiRequest(ctx).
+func (c *Sandbox) StopSandbox(ctx context.Context, opts ...WaitOption) error {
+	opt := newWaitOptions()
+	for _, o := range opts {
+		o(opt)
+	}
+
+	res, err := c.GetSandbox(ctx)
+	if err != nil {
+		return err
+	}
+	if err := res.State.checkTransition(StateStopped); err != nil {
+		return err
+	}
+
+	resp, err := c.client.request(ctx, OpStop).
 		SetContext(ctx).
 		SetError(ErrorResponse{}).
 		Post("/secvirt/v2/sandboxes/" + c.ID + "/stop")
@@ -180,11 +218,29 @@ func (c *Sandbox) StopSandbox(ctx context.Context) error {
 		return resp.Error().(*ErrorResponse)
 	}
 
-	return nil
+	if opt.noWait {
+		return nil
+	}
+
+	_, err = c.waitForState(ctx, StateStopped, res.State, opt)
+	return err
 }
 
-func (c *Sandbox) StartSandbox(ctx context.Context) error {
-	resp, err := c.ApiRequest(ctx).
+func (c *Sandbox) StartSandbox(ctx context.Context, opts ...WaitOption) error {
+	opt := newWaitOptions()
+	for _, o := range opts {
+		o(opt)
+	}
+
+	res, err := c.GetSandbox(ctx)
+	if err != nil {
+		return err
+	}
+	if err := res.State.checkTransition(StateRunning); err != nil {
+		return err
+	}
+
+	resp, err := c.client.request(ctx, OpStart).
 		SetContext(ctx).
 		SetError(ErrorResponse{}).
 		Post("/secvirt/v2/sandboxes/" + c.ID + "/start")
@@ -195,11 +251,16 @@ func (c *Sandbox) StartSandbox(ctx context.Context) error {
 		return resp.Error().(*ErrorResponse)
 	}
 
-	return nil
+	if opt.noWait {
+		return nil
+	}
+
+	_, err = c.waitForState(ctx, StateRunning, res.State, opt)
+	return err
 }
 
 func (c *Sandbox) DestroySandbox(ctx context.Context) error {
-	resp, err := c.ApiRequest(ctx).
+	resp, err := c.client.request(ctx, OpDestroy).
 		SetContext(ctx).
 		SetError(ErrorResponse{}).
 		Post("/secvirt/v2/sandboxes/" + c.ID + "/destroy")
@@ -214,7 +275,7 @@ func (c *Sandbox) DestroySandbox(ctx context.Context) error {
 }
 
 func (c *Sandbox) PackageInstall(ctx context.Context, req PackageInstallRequest) (*InstallDetail, error) {
-	resp, err := c.ApiRequest(ctx).
+	resp, err := c.client.request(ctx, OpPackageInstall).
 		SetContext(ctx).
 		SetBody(map[string]any{
 			"user_id":      c.User,
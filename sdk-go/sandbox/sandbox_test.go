@@ -1,9 +1,22 @@
 package sandbox
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSandbox(t *testing.T) {
@@ -13,3 +26,447 @@ func TestSandbox(t *testing.T) {
 
 	sbx.DestroySandbox(t.Context())
 }
+
+func newAPIServer(t *testing.T, handler http.HandlerFunc) []Option {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(u.Port())
+	require.NoError(t, err)
+
+	return []Option{
+		WithHost(u.Hostname()),
+		WithAPIPort(port),
+		WithProxyPort(port),
+	}
+}
+
+func TestAttach(t *testing.T) {
+	details := map[string]SandboxDetail{
+		"sbx-running": {ID: "sbx-running", Name: "n1", User: "u1", State: "running"},
+		"sbx-stopped": {ID: "sbx-stopped", Name: "n2", User: "u1", State: "stopped"},
+	}
+
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		id := r.URL.Path[len("/secvirt/v2/sandboxes/"):]
+		detail, ok := details[id]
+		if r.Method != http.MethodGet || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ErrorResponse{Code: 404, Message: "not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(detail)
+	})
+
+	sbx, err := Attach(t.Context(), "sbx-running", opts...)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", sbx.Name)
+	assert.NotNil(t, sbx.Filesystem())
+	assert.NotNil(t, sbx.Cmd())
+	assert.NotNil(t, sbx.Pty())
+
+	sbx, err = NewSandbox(t.Context(), append(opts, WithSandboxID("sbx-running"))...)
+	require.NoError(t, err)
+	assert.Equal(t, "sbx-running", sbx.ID)
+
+	_, err = Attach(t.Context(), "sbx-stopped", opts...)
+	assert.ErrorIs(t, err, ErrSandboxStopped)
+
+	_, err = Attach(t.Context(), "sbx-missing", opts...)
+	var errResp *ErrorResponse
+	assert.ErrorAs(t, err, &errResp)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.False(t, IsRetryable(err))
+}
+
+func TestClientList(t *testing.T) {
+	all := make([]SandboxDetail, 0)
+	for i := range 5 {
+		all = append(all, SandboxDetail{ID: fmt.Sprintf("sbx-%d", i), User: "u1"})
+	}
+
+	var queries []map[string]string
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		queries = append(queries, map[string]string{
+			"user_id":        q.Get("user_id"),
+			"template":       q.Get("template"),
+			"created_before": q.Get("created_before"),
+		})
+
+		page, _ := strconv.Atoi(q.Get("page"))
+		size, _ := strconv.Atoi(q.Get("page_size"))
+		start := min((page-1)*size, len(all))
+		end := min(start+size, len(all))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(all[start:end])
+	})
+
+	before := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	client := NewClient(opts...)
+
+	res, err := client.List(t.Context(), ListFilter{
+		User:          "u1",
+		Template:      TemplateDesktop,
+		CreatedBefore: before,
+		PageSize:      2,
+	})
+	require.NoError(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, map[string]string{
+		"user_id":        "u1",
+		"template":       "desktop",
+		"created_before": "2026-01-02T03:04:05Z",
+	}, queries[0])
+
+	res, err = client.ListAll(t.Context(), ListFilter{PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, all, res)
+	assert.Len(t, queries, 4)
+}
+
+func TestClientListAllIgnoredPaging(t *testing.T) {
+	all := []SandboxDetail{{ID: "sbx-0"}, {ID: "sbx-1"}}
+	var requests atomic.Int32
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(all)
+	})
+
+	res, err := NewClient(opts...).ListAll(t.Context(), ListFilter{PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, all, res)
+	assert.EqualValues(t, 2, requests.Load())
+}
+
+type countingTransport struct {
+	requests atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClientWithTransport(t *testing.T) {
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SandboxDetail{ID: "sbx", State: StateRunning})
+	})
+
+	tr := &countingTransport{}
+	sbx, err := NewSandbox(t.Context(), append(opts, WithTransport(tr))...)
+	require.NoError(t, err)
+	assert.Same(t, tr, sbx.Transport())
+
+	_, err = sbx.GetSandbox(t.Context())
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, tr.requests.Load())
+
+	// Without options every sandbox shares the default transport.
+	other, err := NewSandbox(t.Context(), opts...)
+	require.NoError(t, err)
+	assert.Same(t, spec.DefaultTransport(), other.Transport())
+}
+
+func TestKeepAlive(t *testing.T) {
+	var beats atomic.Int32
+	var reaped atomic.Bool
+
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/process.Process/List" {
+			if reaped.Load() {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			beats.Add(1)
+			w.Header().Set("Content-Type", "application/proto")
+			return
+		}
+
+		state := StateRunning
+		if reaped.Load() {
+			state = StateDestroyed
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SandboxDetail{ID: "sbx", Name: "sbx", State: state})
+	})
+
+	sbx, err := Attach(t.Context(), "sbx", opts...)
+	require.NoError(t, err)
+
+	errCh := make(chan error, 4)
+	sbx.KeepAlive(t.Context(), 5*time.Millisecond,
+		WithKeepAliveError(func(err error) { errCh <- err }))
+
+	require.Eventually(t, func() bool { return beats.Load() >= 3 },
+		time.Second, 5*time.Millisecond)
+	reaped.Store(true)
+
+	var last error
+	for err := range errCh {
+		last = err
+		if errors.Is(err, ErrSandboxDestroyed) {
+			break
+		}
+	}
+	assert.ErrorIs(t, last, ErrSandboxDestroyed)
+}
+
+func TestCreateWithResources(t *testing.T) {
+	var body map[string]any
+	var destroyed bool
+	dropEnvs := false
+
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/destroy") {
+			destroyed = true
+			return
+		}
+
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		detail := SandboxDetail{ID: "sbx", Name: "sbx", CpuLimit: 2, MemLimit: 1 << 30, Timeout: 600}
+		if !dropEnvs {
+			detail.Envs = []string{"PATH=/usr/bin", "FOO=bar"}
+		}
+		detail.Binds = []string{"/data:/mnt/data:ro"}
+		json.NewEncoder(w).Encode(detail)
+	})
+
+	opts = append(opts,
+		WithCPULimit(2),
+		WithMemoryLimit(1<<30),
+		WithEnv("FOO", "bar"),
+		WithBind("/data", "/mnt/data", true),
+		WithIdleTimeout(10*time.Minute),
+	)
+
+	sbx, err := NewSandbox(t.Context(), opts...)
+	require.NoError(t, err)
+	assert.Equal(t, "sbx", sbx.ID)
+	assert.Equal(t, float64(2), body["cpu_limit"])
+	assert.Equal(t, float64(1<<30), body["mem_limit"])
+	assert.Equal(t, []any{"FOO=bar"}, body["envs"])
+	assert.Equal(t, []any{"/data:/mnt/data:ro"}, body["binds"])
+	assert.Equal(t, float64(600), body["timeout"])
+	assert.False(t, destroyed)
+
+	dropEnvs = true
+	_, err = NewSandbox(t.Context(), opts...)
+	assert.ErrorIs(t, err, ErrResourceMismatch)
+	assert.True(t, destroyed)
+}
+
+func TestIdleTimeoutRoundsUp(t *testing.T) {
+	for _, tc := range []struct {
+		timeout time.Duration
+		want    int64
+	}{
+		{0, 0},
+		{500 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1500 * time.Millisecond, 2},
+	} {
+		opt := &Options{}
+		WithIdleTimeout(tc.timeout)(opt)
+		assert.Equal(t, tc.want, opt.resources.timeout, "%v", tc.timeout)
+	}
+}
+
+func TestCreateIdempotencyKey(t *testing.T) {
+	var keys []string
+	failures := 2
+
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		}
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SandboxDetail{ID: "sbx", State: StateRunning})
+	})
+
+	fast := RetryPolicy{Count: 3, WaitTime: time.Millisecond, MaxWaitTime: time.Millisecond}
+	sbx, err := NewSandbox(t.Context(), append(opts, WithRetryPolicy(OpCreate, fast))...)
+	require.NoError(t, err)
+
+	require.Len(t, keys, 3)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+	assert.Equal(t, keys[0], keys[2])
+
+	// Reads carry no key, and every mutating call gets a fresh one.
+	keys = nil
+	_, err = sbx.GetSandbox(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	require.NoError(t, sbx.DestroySandbox(t.Context()))
+	require.NoError(t, sbx.DestroySandbox(t.Context()))
+	require.Len(t, keys, 2)
+	assert.NotEqual(t, keys[0], keys[1])
+}
+
+func TestRetryPolicyPerOperation(t *testing.T) {
+	var attempts int
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	_, err := NewSandbox(t.Context(), append(opts, WithRetryPolicy(OpCreate, RetryPolicy{}))...)
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestSnapshotFork(t *testing.T) {
+	var created []map[string]any
+	var restored string
+
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch {
+		case r.URL.Path == "/secvirt/v2/sandboxes":
+			created = append(created, body)
+			var envs []string
+			for _, env := range body["envs"].([]any) {
+				envs = append(envs, env.(string))
+			}
+			json.NewEncoder(w).Encode(SandboxDetail{ID: "sbx-" + string(rune('0'+len(created))), User: "u1", Envs: envs})
+		case strings.HasSuffix(r.URL.Path, "/snapshots"):
+			json.NewEncoder(w).Encode(SnapshotDetail{ID: "snap-1", Name: body["name"].(string), SandboxID: "sbx-1"})
+		case strings.HasSuffix(r.URL.Path, "/restore"):
+			restored = body["snapshot_id"].(string)
+		}
+	})
+
+	sbx, err := NewSandbox(t.Context(), append(opts, WithUser("u1"), WithTemplate(TemplateDesktop),
+		WithEnv("A", "1"), WithEnv("B", "2"), WithEnv("C", "3"),
+		WithRetryPolicy(OpCreate, DefaultRetryPolicy))...)
+	require.NoError(t, err)
+
+	snapshot, err := sbx.Snapshot(t.Context(), "prepared")
+	require.NoError(t, err)
+	assert.Equal(t, "snap-1", snapshot.ID)
+	assert.Equal(t, "prepared", snapshot.Name)
+
+	require.NoError(t, sbx.RestoreSandbox(t.Context(), snapshot.ID))
+	assert.Equal(t, "snap-1", restored)
+
+	fork, err := sbx.Fork(t.Context(), WithEnv("D", "4"), WithRetryPolicy(OpRestore, RetryPolicy{}))
+	require.NoError(t, err)
+	_, err = sbx.Fork(t.Context(), WithEnv("E", "5"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A=1", "B=2", "C=3", "D=4"}, fork.opt.resources.envs)
+	assert.Len(t, sbx.opt.resources.envs, 3)
+	assert.Len(t, sbx.opt.retryPolicies, 1)
+	assert.Equal(t, "sbx-2", fork.ID)
+	require.Len(t, created, 3)
+	assert.Equal(t, "snap-1", created[1]["snapshot_id"])
+	assert.Equal(t, "desktop", created[1]["template"])
+	assert.Equal(t, "u1", created[1]["user_id"])
+}
+
+func TestStateTransitions(t *testing.T) {
+	assert.True(t, StateStopped.CanTransitionTo(StateRunning))
+	assert.True(t, StateRunning.CanTransitionTo(StateStopped))
+	assert.False(t, StateDestroyed.CanTransitionTo(StateRunning))
+	assert.False(t, StateCreating.CanTransitionTo(StateStopped))
+	assert.True(t, State("paused").CanTransitionTo(StateRunning))
+
+	err := StateDestroyed.checkTransition(StateRunning)
+	assert.ErrorIs(t, err, ErrInvalidState)
+	assert.ErrorIs(t, err, ErrSandboxDestroyed)
+}
+
+func TestStartWaitReady(t *testing.T) {
+	var state atomic.Value
+	state.Store(StateStopped)
+	var polls, probes atomic.Int32
+
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if host := r.Header.Get("X-HOST"); len(host) > 0 {
+			if !strings.HasPrefix(host, "8000-") || probes.Add(1) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/start"):
+			state.Store(StateCreating)
+		case r.Method == http.MethodGet:
+			if polls.Add(1) > 3 && state.Load() == StateCreating {
+				state.Store(StateRunning)
+			}
+			json.NewEncoder(w).Encode(SandboxDetail{
+				ID:          "sbx",
+				Name:        "sbx",
+				State:       state.Load().(State),
+				HealthPorts: []int{8000},
+			})
+		}
+	})
+
+	sbx := newSandbox(newTestOptions(opts))
+	sbx.SandboxDetail = &SandboxDetail{ID: "sbx", Name: "sbx"}
+
+	poll := WithPollInterval(time.Millisecond, 5*time.Millisecond)
+	require.NoError(t, sbx.StartSandbox(t.Context(), poll))
+	assert.Equal(t, StateRunning, state.Load())
+
+	require.NoError(t, sbx.WaitReady(t.Context(), poll))
+	assert.Equal(t, int32(3), probes.Load())
+
+	state.Store(StateDestroyed)
+	err := sbx.StartSandbox(t.Context(), poll)
+	assert.ErrorIs(t, err, ErrSandboxDestroyed)
+	_, err = sbx.WaitForState(t.Context(), StateRunning, poll)
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
+
+func TestWaitError(t *testing.T) {
+	var polls atomic.Int32
+	opts := newAPIServer(t, func(w http.ResponseWriter, r *http.Request) {
+		polls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(SandboxDetail{ID: "sbx", Name: "sbx", State: StateError})
+	})
+
+	sbx := newSandbox(newTestOptions(opts))
+	sbx.SandboxDetail = &SandboxDetail{ID: "sbx", Name: "sbx"}
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Minute)
+	defer cancel()
+	assert.ErrorIs(t, sbx.WaitReady(ctx), ErrSandboxFailed)
+	_, err := sbx.WaitForState(ctx, StateStopped)
+	assert.ErrorIs(t, err, ErrSandboxFailed)
+	assert.Equal(t, int32(2), polls.Load())
+
+	res, err := sbx.WaitForState(ctx, StateError)
+	require.NoError(t, err)
+	assert.Equal(t, StateError, res.State)
+}
+
+func newTestOptions(opts []Option) *Options {
+	opt := newOptions()
+	for _, o := range opts {
+		o(opt)
+	}
+	return opt
+}
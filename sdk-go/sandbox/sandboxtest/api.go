@@ -0,0 +1,291 @@
+package sandboxtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/llm-infra/secvirt/sdk-go/internal/envd"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+)
+
+type createRequest struct {
+	UserID      string               `json:"user_id"`
+	Template    sandbox.TemplateType `json:"template"`
+	HealthPorts []int                `json:"health_ports"`
+	CpuLimit    int64                `json:"cpu_limit"`
+	MemLimit    uint64               `json:"mem_limit"`
+	Envs        []string             `json:"envs"`
+	Binds       []string             `json:"binds"`
+	Timeout     int64                `json:"timeout"`
+	SnapshotID  string               `json:"snapshot_id"`
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /secvirt/v2/sandboxes", s.create)
+	mux.HandleFunc("GET /secvirt/v2/sandboxes", s.list)
+	mux.HandleFunc("GET /secvirt/v2/sandboxes/{id}", s.get)
+	mux.HandleFunc("POST /secvirt/v2/sandboxes/{id}/start", s.transition(sandbox.StateRunning))
+	mux.HandleFunc("POST /secvirt/v2/sandboxes/{id}/stop", s.transition(sandbox.StateStopped))
+	mux.HandleFunc("POST /secvirt/v2/sandboxes/{id}/destroy", s.transition(sandbox.StateDestroyed))
+	if status := s.opt.snapshotStatus; status > 0 {
+		unsupported := func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(status), status)
+		}
+		mux.HandleFunc("POST /secvirt/v2/sandboxes/{id}/snapshots", unsupported)
+		mux.HandleFunc("POST /secvirt/v2/sandboxes/{id}/restore", unsupported)
+		mux.HandleFunc("DELETE /secvirt/v2/snapshots/{id}", unsupported)
+		return mux
+	}
+	mux.HandleFunc("POST /secvirt/v2/sandboxes/{id}/snapshots", s.snapshot)
+	mux.HandleFunc("POST /secvirt/v2/sandboxes/{id}/restore", s.restore)
+	mux.HandleFunc("DELETE /secvirt/v2/snapshots/{id}", s.deleteSnapshot)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(sandbox.ErrorResponse{Code: status, Message: msg})
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := uuid.NewString()
+	detail := sandbox.SandboxDetail{
+		ID:             id,
+		Name:           "sbx" + id[:8],
+		IP:             "127.0.0.1",
+		User:           req.UserID,
+		Template:       req.Template,
+		CreateAt:       time.Now().Format(time.RFC3339),
+		CpuLimit:       req.CpuLimit,
+		MemLimit:       req.MemLimit,
+		Envs:           req.Envs,
+		Binds:          req.Binds,
+		Timeout:        req.Timeout,
+		HealthPorts:    req.HealthPorts,
+		State:          sandbox.StateRunning,
+		LastActionTime: time.Now().Unix(),
+	}
+
+	home := s.homeDir(&detail)
+	if len(req.SnapshotID) > 0 {
+		s.mu.Lock()
+		_, ok := s.snapshots[req.SnapshotID]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "snapshot "+req.SnapshotID+" not found")
+			return
+		}
+		if err := copyDir(home, s.snapshotDir(req.SnapshotID)); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else if err := os.MkdirAll(home, 0o755); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	opts := []envd.Option{envd.WithRoot(s.Dir(detail.Name)), envd.WithEnvs(req.Envs)}
+	if s.opt.keepAlive > 0 {
+		opts = append(opts, envd.WithKeepAlive(s.opt.keepAlive))
+	}
+	srv := envd.New(opts...)
+	sbx := &fakeSandbox{
+		detail:  detail,
+		envd:    srv,
+		handler: srv.Handler(),
+	}
+
+	s.mu.Lock()
+	s.sandboxes[id] = sbx
+	s.order = append(s.order, id)
+	s.mu.Unlock()
+
+	writeJSON(w, detail)
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	page = max(page, 1)
+	size, _ := strconv.Atoi(q.Get("page_size"))
+	if size <= 0 {
+		size = 100
+	}
+
+	s.mu.Lock()
+	matched := make([]sandbox.SandboxDetail, 0)
+	for _, id := range s.order {
+		sbx := s.sandboxes[id]
+		detail := sbx.detail
+		if (q.Has("user_id") && detail.User != q.Get("user_id")) ||
+			(q.Has("template") && string(detail.Template) != q.Get("template")) ||
+			(q.Has("state") && string(detail.State) != q.Get("state")) {
+			continue
+		}
+		matched = append(matched, detail)
+	}
+	s.mu.Unlock()
+
+	start := min((page-1)*size, len(matched))
+	end := min(start+size, len(matched))
+	writeJSON(w, matched[start:end])
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	detail, ok := s.detail(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "sandbox not found")
+		return
+	}
+	writeJSON(w, detail)
+}
+
+// transition moves a sandbox to state. Stopping or destroying a sandbox
+// kills its processes.
+func (s *Server) transition(state sandbox.State) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		sbx, ok := s.sandboxes[r.PathValue("id")]
+		if !ok {
+			s.mu.Unlock()
+			writeError(w, http.StatusNotFound, "sandbox not found")
+			return
+		}
+		if sbx.detail.State == sandbox.StateDestroyed && state != sandbox.StateDestroyed {
+			s.mu.Unlock()
+			writeError(w, http.StatusConflict, "sandbox is destroyed")
+			return
+		}
+		if state == sandbox.StateRunning && s.opt.startDelay > 0 {
+			detail := sbx.detail
+			s.mu.Unlock()
+
+			id := detail.ID
+			time.AfterFunc(s.opt.startDelay, func() { s.SetState(id, state) })
+			writeJSON(w, detail)
+			return
+		}
+		sbx.detail.State = state
+		sbx.detail.LastActionTime = time.Now().Unix()
+		detail := sbx.detail
+		s.mu.Unlock()
+
+		if state != sandbox.StateRunning {
+			sbx.envd.Close()
+		}
+		if state == sandbox.StateDestroyed {
+			os.RemoveAll(s.Dir(detail.Name))
+		}
+		writeJSON(w, detail)
+	}
+}
+
+func (s *Server) snapshot(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	detail, ok := s.detail(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "sandbox not found")
+		return
+	}
+
+	snapshot := &sandbox.SnapshotDetail{
+		ID:        uuid.NewString(),
+		Name:      req.Name,
+		SandboxID: detail.ID,
+		CreateAt:  time.Now().Format(time.RFC3339),
+	}
+	if err := copyDir(s.snapshotDir(snapshot.ID), s.homeDir(&detail)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.snapshots[snapshot.ID] = snapshot
+	s.mu.Unlock()
+
+	writeJSON(w, snapshot)
+}
+
+func (s *Server) restore(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	detail, ok := s.detail(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "sandbox not found")
+		return
+	}
+
+	s.mu.Lock()
+	_, ok = s.snapshots[req.SnapshotID]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "snapshot "+req.SnapshotID+" not found")
+		return
+	}
+
+	if err := copyDir(s.homeDir(&detail), s.snapshotDir(req.SnapshotID)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, detail)
+}
+
+func (s *Server) deleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	_, ok := s.snapshots[id]
+	delete(s.snapshots, id)
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "snapshot "+id+" not found")
+		return
+	}
+
+	if err := os.RemoveAll(s.snapshotDir(id)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) detail(id string) (sandbox.SandboxDetail, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sbx, ok := s.sandboxes[id]
+	if !ok {
+		return sandbox.SandboxDetail{}, false
+	}
+	return sbx.detail, true
+}
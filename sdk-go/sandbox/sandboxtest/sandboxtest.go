@@ -0,0 +1,350 @@
+// Package sandboxtest runs an in-process fake of the secvirt API and proxy,
+// so code built on the sandbox package can be tested without a cluster.
+//
+// Each sandbox gets its own directory; its filesystem is mapped under it and
+// its commands run on the host through os/exec, with the working directory
+// and HOME mapped. Requests for other ports are forwarded to the same port on
+// 127.0.0.1, also through CONNECT.
+package sandboxtest
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/llm-infra/secvirt/sdk-go/internal/envd"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+)
+
+// New starts a fake backend for the test and returns the options that point
+// sandbox.NewSandbox, sandbox.Attach and sandbox.NewClient at it.
+func New(tb testing.TB, opts ...Option) []sandbox.Option {
+	return NewServer(tb, opts...).Options()
+}
+
+// NewSandbox starts a fake backend for the test and creates a sandbox on it.
+func NewSandbox(tb testing.TB, opts ...Option) *sandbox.Sandbox {
+	tb.Helper()
+	return NewServer(tb, opts...).NewSandbox(tb)
+}
+
+type Option func(*Options)
+
+type Options struct {
+	keepAlive      time.Duration
+	snapshotStatus int
+	startDelay     time.Duration
+}
+
+// WithKeepAlive sets how often envd sends keepalives on idle streams.
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *Options) { o.keepAlive = d }
+}
+
+// WithStartDelay makes a started sandbox keep its state for d before it runs,
+// like a backend that starts sandboxes asynchronously.
+func WithStartDelay(d time.Duration) Option {
+	return func(o *Options) { o.startDelay = d }
+}
+
+// WithoutSnapshots makes the snapshot and restore endpoints answer status
+// without an error body, like a backend that lacks them.
+func WithoutSnapshots(status int) Option {
+	return func(o *Options) { o.snapshotStatus = status }
+}
+
+type Server struct {
+	srv *httptest.Server
+	dir string
+	api http.Handler
+	opt Options
+
+	// Envd responses started before the last StallStreams stop moving, all of
+	// them while stallNew is set.
+	epoch    atomic.Int64
+	stallNew atomic.Bool
+
+	mu        sync.Mutex
+	sandboxes map[string]*fakeSandbox
+	order     []string
+	snapshots map[string]*sandbox.SnapshotDetail
+}
+
+type fakeSandbox struct {
+	detail  sandbox.SandboxDetail
+	envd    *envd.Server
+	handler http.Handler
+}
+
+// NewServer starts a fake backend that is shut down with the test.
+func NewServer(tb testing.TB, opts ...Option) *Server {
+	tb.Helper()
+
+	s := &Server{
+		dir:       tb.TempDir(),
+		sandboxes: make(map[string]*fakeSandbox),
+		snapshots: make(map[string]*sandbox.SnapshotDetail),
+	}
+	for _, o := range opts {
+		o(&s.opt)
+	}
+	s.api = s.routes()
+	s.srv = httptest.NewServer(s)
+	tb.Cleanup(s.Close)
+
+	return s
+}
+
+// Options points the sandbox clients at the fake; the API and the proxy share
+// one port.
+func (s *Server) Options() []sandbox.Option {
+	addr := s.srv.Listener.Addr().(*net.TCPAddr)
+	return []sandbox.Option{
+		sandbox.WithHost(addr.IP.String()),
+		sandbox.WithAPIPort(addr.Port),
+		sandbox.WithProxyPort(addr.Port),
+	}
+}
+
+// NewSandbox creates a sandbox on the fake with opts added to Options, and
+// fails the test when it cannot.
+func (s *Server) NewSandbox(tb testing.TB, opts ...sandbox.Option) *sandbox.Sandbox {
+	tb.Helper()
+
+	sbx, err := sandbox.NewSandbox(tb.Context(), append(s.Options(), opts...)...)
+	if err != nil {
+		tb.Fatalf("create sandbox: %v", err)
+	}
+	return sbx
+}
+
+// URL is the base URL of the fake.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Dir returns the host directory that holds the filesystem of a sandbox.
+func (s *Server) Dir(name string) string {
+	return filepath.Join(s.dir, "sandboxes", name)
+}
+
+// Snapshots returns the IDs of the snapshots that were not deleted.
+func (s *Server) Snapshots() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.snapshots))
+	for id := range s.snapshots {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// SetState moves a sandbox to state without anything else, e.g. to
+// StateError.
+func (s *Server) SetState(id string, state sandbox.State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sbx, ok := s.sandboxes[id]; ok {
+		sbx.detail.State = state
+		sbx.detail.LastActionTime = time.Now().Unix()
+	}
+}
+
+// Close kills every process and stops the server.
+func (s *Server) Close() {
+	s.srv.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sbx := range s.sandboxes {
+		sbx.envd.Close()
+	}
+}
+
+// ServeHTTP routes requests carrying an X-HOST header like the proxy does and
+// everything else to the API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Header.Get("X-HOST")
+	if len(host) == 0 {
+		s.api.ServeHTTP(w, r)
+		return
+	}
+
+	port, name, ok := parseHost(host)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid X-HOST "+host)
+		return
+	}
+
+	sbx, state := s.byName(name)
+	if sbx == nil {
+		writeError(w, http.StatusNotFound, "sandbox "+name+" not found")
+		return
+	}
+	if state != sandbox.StateRunning {
+		writeError(w, http.StatusServiceUnavailable, "container waiting")
+		return
+	}
+
+	if port == spec.DefaultEnvdPort {
+		sbx.handler.ServeHTTP(&stallWriter{
+			ResponseWriter: w, s: s, r: r,
+			epoch: s.epoch.Load(), stallNew: s.stallNew.Load(),
+		}, r)
+		return
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	if r.Method == http.MethodConnect {
+		tunnel(w, addr)
+		return
+	}
+	httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: addr}).ServeHTTP(w, r)
+}
+
+// StallStreams makes the envd responses in flight stop delivering anything, as
+// over a half-open connection, until their request ends. New requests work.
+func (s *Server) StallStreams() {
+	s.epoch.Add(1)
+}
+
+// StallNewStreams makes the envd responses started while stall is set stop
+// delivering anything as well, as when every reconnect goes through the same
+// half-open proxy.
+func (s *Server) StallNewStreams(stall bool) {
+	s.stallNew.Store(stall)
+}
+
+type stallWriter struct {
+	http.ResponseWriter
+	s        *Server
+	r        *http.Request
+	epoch    int64
+	stallNew bool
+}
+
+func (w *stallWriter) stall() bool {
+	if w.s.epoch.Load() == w.epoch && !w.stallNew {
+		return false
+	}
+	<-w.r.Context().Done()
+	return true
+}
+
+func (w *stallWriter) Write(b []byte) (int, error) {
+	if w.stall() {
+		return 0, w.r.Context().Err()
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *stallWriter) Flush() {
+	if !w.stall() {
+		http.NewResponseController(w.ResponseWriter).Flush()
+	}
+}
+
+func (w *stallWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// tunnel answers a CONNECT request and splices the connection to addr.
+func tunnel(w http.ResponseWriter, addr string) {
+	remote, err := net.Dial("tcp", addr)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer remote.Close()
+
+	local, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer local.Close()
+
+	if _, err := local.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, buf)
+		closeWrite(remote)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(local, remote)
+		closeWrite(local)
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+}
+
+// parseHost splits "<port>-<name>.proxy.com".
+func parseHost(host string) (int, string, bool) {
+	host = strings.TrimSuffix(host, spec.DefaultProxyHostSuffix)
+	p, name, ok := strings.Cut(host, "-")
+	if !ok {
+		return 0, "", false
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return 0, "", false
+	}
+	return port, name, true
+}
+
+func (s *Server) byName(name string) (*fakeSandbox, sandbox.State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sbx := range s.sandboxes {
+		if sbx.detail.Name == name {
+			return sbx, sbx.detail.State
+		}
+	}
+	return nil, ""
+}
+
+func (s *Server) homeDir(detail *sandbox.SandboxDetail) string {
+	return filepath.Join(s.Dir(detail.Name), "home", detail.User)
+}
+
+func (s *Server) snapshotDir(id string) string {
+	return filepath.Join(s.dir, "snapshots", id)
+}
+
+// copyDir replaces dst with a copy of src.
+func copyDir(dst, src string) error {
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	if err := os.CopyFS(dst, os.DirFS(src)); err != nil {
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package sandboxtest_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/commands"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/sandboxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesystem(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+	fs := sbx.Filesystem()
+
+	ok, err := fs.Mkdir(t.Context(), "/app/ttt")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = fs.Mkdir(t.Context(), "/app/ttt")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, fs.Write(t.Context(), "readme", []byte("hello")))
+	data, err := fs.Read(t.Context(), sbx.HomeDir()+"/readme")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	entries, err := fs.List(t.Context(), "/app", 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "/app/ttt", entries[0].Path)
+
+	entry, err := fs.Rename(t.Context(), "readme", "/app/readme")
+	require.NoError(t, err)
+	assert.Equal(t, "/app/readme", entry.Path)
+
+	require.NoError(t, fs.Remove(t.Context(), "/app"))
+	_, err = fs.Exist(t.Context(), "/app")
+	assert.ErrorIs(t, err, sandbox.ErrNotFound)
+}
+
+func TestCommands(t *testing.T) {
+	sbx := sandboxtest.NewSandbox(t)
+
+	res, err := sbx.Cmd().Run(t.Context(), "echo $FOO; pwd; echo oops >&2; exit 3",
+		map[string]string{"FOO": "bar"}, "", false)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, res.ExitCode)
+	assert.Contains(t, res.Stdout, "bar\n")
+	assert.Contains(t, res.Stdout, "/home/"+sbx.User)
+	assert.Equal(t, "oops\n", res.Stderr)
+
+	h, err := sbx.Cmd().Start(t.Context(), "read line; echo got $line", nil, "", true)
+	require.NoError(t, err)
+
+	procs, err := sbx.Cmd().List(t.Context())
+	require.NoError(t, err)
+	require.Len(t, procs, 1)
+	assert.Equal(t, h.Pid(), procs[0].Pid())
+
+	require.NoError(t, sbx.Cmd().SendStdin(t.Context(), h.Pid(), []byte("hi\n")))
+	res, err = h.Wait(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "got hi\n", res.Stdout)
+
+	h, err = sbx.Cmd().Start(t.Context(), "sleep 30", nil, "", false)
+	require.NoError(t, err)
+	require.NoError(t, h.Kill())
+	_, err = h.Wait(t.Context())
+	var exitErr *commands.CommandExitError
+	assert.True(t, errors.As(err, &exitErr))
+}
+
+func TestLifecycle(t *testing.T) {
+	opts := sandboxtest.New(t)
+	sbx, err := sandbox.NewSandbox(t.Context(), append(opts,
+		sandbox.WithUser("u1"), sandbox.WithEnv("FOO", "bar"))...)
+	require.NoError(t, err)
+
+	require.NoError(t, sbx.Filesystem().Write(t.Context(), "state", []byte("before")))
+	snapshot, err := sbx.Snapshot(t.Context(), "s1")
+	require.NoError(t, err)
+	require.NoError(t, sbx.Filesystem().Write(t.Context(), "state", []byte("after")))
+
+	require.NoError(t, sbx.RestoreSandbox(t.Context(), snapshot.ID))
+	data, err := sbx.Filesystem().Read(t.Context(), "state")
+	require.NoError(t, err)
+	assert.Equal(t, "before", string(data))
+
+	fork, err := sbx.Fork(t.Context())
+	require.NoError(t, err)
+	assert.NotEqual(t, sbx.ID, fork.ID)
+	data, err = fork.Filesystem().Read(t.Context(), "state")
+	require.NoError(t, err)
+	assert.Equal(t, "before", string(data))
+
+	list, err := sandbox.NewClient(opts...).List(t.Context(), sandbox.ListFilter{User: "u1"})
+	require.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	require.NoError(t, sbx.StopSandbox(t.Context()))
+	_, err = sandbox.Attach(t.Context(), sbx.ID, opts...)
+	assert.ErrorIs(t, err, sandbox.ErrSandboxStopped)
+
+	require.NoError(t, sbx.StartSandbox(t.Context()))
+	attached, err := sandbox.Attach(t.Context(), sbx.ID, opts...)
+	require.NoError(t, err)
+	res, err := attached.Cmd().Run(t.Context(), "echo $FOO", nil, "", false)
+	require.NoError(t, err)
+	assert.Equal(t, "bar\n", res.Stdout)
+
+	require.NoError(t, sbx.DestroySandbox(t.Context()))
+	_, err = sandbox.Attach(t.Context(), sbx.ID, opts...)
+	assert.ErrorIs(t, err, sandbox.ErrSandboxDestroyed)
+}
+
+func TestStartFromError(t *testing.T) {
+	srv := sandboxtest.NewServer(t, sandboxtest.WithStartDelay(100*time.Millisecond))
+	sbx := srv.NewSandbox(t)
+
+	srv.SetState(sbx.ID, sandbox.StateError)
+	_, err := sbx.WaitForState(t.Context(), sandbox.StateRunning)
+	assert.ErrorIs(t, err, sandbox.ErrSandboxFailed)
+
+	// The sandbox reports the error until the start takes effect.
+	poll := sandbox.WithPollInterval(10*time.Millisecond, 10*time.Millisecond)
+	require.NoError(t, sbx.StartSandbox(t.Context(), poll))
+	res, err := sbx.GetSandbox(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, sandbox.StateRunning, res.State)
+}
+
+func TestSnapshotFallback(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusNotImplemented} {
+		opts := append(sandboxtest.New(t, sandboxtest.WithoutSnapshots(status)),
+			sandbox.WithSnapshotDir(t.TempDir()))
+		sbx, err := sandbox.NewSandbox(t.Context(), append(opts,
+			sandbox.WithUser("u1"), sandbox.WithTemplate(sandbox.TemplateDesktop))...)
+		require.NoError(t, err)
+
+		require.NoError(t, sbx.Filesystem().Write(t.Context(), "state", []byte("before")))
+		snapshot, err := sbx.Snapshot(t.Context(), "s1")
+		require.NoError(t, err, "status %d", status)
+		assert.True(t, strings.HasPrefix(snapshot.ID, "file://"))
+		require.NoError(t, sbx.Filesystem().Write(t.Context(), "state", []byte("after")))
+
+		require.NoError(t, sbx.RestoreSandbox(t.Context(), snapshot.ID))
+		data, err := sbx.Filesystem().Read(t.Context(), "state")
+		require.NoError(t, err)
+		assert.Equal(t, "before", string(data))
+
+		// A fork of an attached sandbox keeps its template.
+		attached, err := sandbox.Attach(t.Context(), sbx.ID, opts...)
+		require.NoError(t, err)
+		fork, err := attached.Fork(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, sandbox.TemplateDesktop, fork.Template)
+		data, err = fork.Filesystem().Read(t.Context(), "state")
+		require.NoError(t, err)
+		assert.Equal(t, "before", string(data))
+	}
+
+	// A missing sandbox is not mistaken for a missing endpoint.
+	sbx := sandboxtest.NewSandbox(t)
+	sbx.ID = "missing"
+	_, err := sbx.Snapshot(t.Context(), "s1")
+	assert.ErrorIs(t, err, sandbox.ErrNotFound)
+}
+
+func TestForwardPortHTTP(t *testing.T) {
+	svc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello %s", r.URL.Path)
+	}))
+	defer svc.Close()
+	port := svc.Listener.Addr().(*net.TCPAddr).Port
+
+	sbx := sandboxtest.NewSandbox(t)
+
+	f, err := sbx.ForwardPort(t.Context(), "127.0.0.1:0", port)
+	require.NoError(t, err)
+	defer f.Close()
+
+	resp, err := http.Get("http://" + f.Addr().String() + "/dev")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "hello /dev", string(body))
+}
+
+func TestForwardPortTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				conn.Write([]byte("echo " + line))
+			}()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// The tunnel dials through the transport of the sandbox.
+	var dials atomic.Int32
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	sbx := sandboxtest.NewServer(t).NewSandbox(t, sandbox.WithTransport(tr))
+
+	f, err := sbx.ForwardPort(t.Context(), "127.0.0.1:0", port, sandbox.WithRawTCP())
+	require.NoError(t, err)
+
+	before := dials.Load()
+	for i := range 3 {
+		conn, err := net.Dial("tcp", f.Addr().String())
+		require.NoError(t, err)
+		_, err = conn.Write([]byte(strconv.Itoa(i) + "\n"))
+		require.NoError(t, err)
+		out, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		assert.Equal(t, "echo "+strconv.Itoa(i)+"\n", string(out))
+		conn.Close()
+	}
+
+	assert.Equal(t, before+3, dials.Load())
+
+	require.NoError(t, f.Close())
+	_, err = net.Dial("tcp", f.Addr().String())
+	assert.Error(t, err)
+}
+
+func newPortService(t *testing.T) int {
+	upgrader := websocket.Upgrader{}
+	svc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			fmt.Fprintf(w, "hello %s", r.URL.Path)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(typ, append([]byte("echo "), msg...))
+		}
+	}))
+	t.Cleanup(svc.Close)
+	return svc.Listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestHTTPClient(t *testing.T) {
+	port := newPortService(t)
+	sbx := sandboxtest.NewSandbox(t)
+
+	resp, err := sbx.HTTPClient(port).Get(sbx.ProxyBaseURL() + "/api")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "hello /api", string(body))
+}
+
+func TestWebSocketDial(t *testing.T) {
+	port := newPortService(t)
+	sbx := sandboxtest.NewSandbox(t)
+
+	conn, _, err := sbx.WebSocketDial(t.Context(), port, "/ws")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hi")))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "echo hi", string(msg))
+}
+
+func TestReverseProxy(t *testing.T) {
+	port := newPortService(t)
+	sbx := sandboxtest.NewSandbox(t)
+
+	mux := http.NewServeMux()
+	mux.Handle("/apps/dev/", sbx.ReverseProxy(port, sandbox.WithStripPrefix("/apps/dev")))
+	front := httptest.NewServer(mux)
+	defer front.Close()
+
+	resp, err := http.Get(front.URL + "/apps/dev/index.html")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, "hello /index.html", string(body))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+front.URL[len("http"):]+"/apps/dev/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hi")))
+	_, msg, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "echo hi", string(msg))
+}
@@ -0,0 +1,194 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/commands"
+)
+
+// localSnapshotPrefix marks snapshots kept as a tar of the home directory on
+// the local disk, used when the backend has no native snapshot support. Such
+// a snapshot only exists on the host that took it: other hosts cannot restore
+// it.
+const localSnapshotPrefix = "file://"
+
+type SnapshotDetail struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	SandboxID string `json:"sandbox_id"`
+	CreateAt  string `json:"create_at"`
+}
+
+func isLocalSnapshot(id string) bool {
+	return strings.HasPrefix(id, localSnapshotPrefix)
+}
+
+// snapshotUnsupported reports whether the backend lacks the snapshot endpoints.
+// A missing sandbox is a 404 with an error body, a missing route has none.
+func snapshotUnsupported(resp *resty.Response) bool {
+	switch resp.StatusCode() {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	case http.StatusNotFound:
+		errResp, _ := resp.Error().(*ErrorResponse)
+		return errResp == nil || (errResp.Code == 0 && len(errResp.Message) == 0)
+	}
+	return false
+}
+
+// Snapshot checkpoints the sandbox. Without native backend support the home
+// directory is archived to a local file instead, see WithSnapshotDir; the
+// file:// ID of such a snapshot is only valid on this host.
+func (c *Sandbox) Snapshot(ctx context.Context, name string) (*SnapshotDetail, error) {
+	resp, err := c.client.request(ctx, OpSnapshot).
+		SetContext(ctx).
+		SetBody(map[string]any{
+			"name": name,
+		}).
+		SetResult(SnapshotDetail{}).
+		SetError(ErrorResponse{}).
+		Post("/secvirt/v2/sandboxes/" + c.ID + "/snapshots")
+	if err != nil {
+		return nil, err
+	}
+	if snapshotUnsupported(resp) {
+		return c.snapshotLocal(ctx, name)
+	}
+	if resp.IsError() {
+		return nil, resp.Error().(*ErrorResponse)
+	}
+
+	return resp.Result().(*SnapshotDetail), nil
+}
+
+// RestoreSandbox resets the sandbox to a snapshot.
+func (c *Sandbox) RestoreSandbox(ctx context.Context, snapshotID string) error {
+	if isLocalSnapshot(snapshotID) {
+		return c.restoreLocal(ctx, snapshotID)
+	}
+
+	resp, err := c.client.request(ctx, OpRestore).
+		SetContext(ctx).
+		SetBody(map[string]any{
+			"snapshot_id": snapshotID,
+		}).
+		SetError(ErrorResponse{}).
+		Post("/secvirt/v2/sandboxes/" + c.ID + "/restore")
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return resp.Error().(*ErrorResponse)
+	}
+
+	return nil
+}
+
+// DeleteSnapshot removes a snapshot. It need not be one of this sandbox: the
+// sandbox only provides the client.
+func (c *Sandbox) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	if isLocalSnapshot(snapshotID) {
+		return os.Remove(strings.TrimPrefix(snapshotID, localSnapshotPrefix))
+	}
+
+	resp, err := c.client.request(ctx, OpDeleteSnapshot).
+		SetContext(ctx).
+		SetError(ErrorResponse{}).
+		Delete("/secvirt/v2/snapshots/" + snapshotID)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return resp.Error().(*ErrorResponse)
+	}
+
+	return nil
+}
+
+// Fork snapshots the sandbox and creates a new sandbox from the snapshot with
+// the options this sandbox was created or attached with, and its template.
+func (c *Sandbox) Fork(ctx context.Context, opts ...Option) (*Sandbox, error) {
+	snapshot, err := c.Snapshot(ctx, fmt.Sprintf("fork-%s-%d", c.ID, time.Now().Unix()))
+	if err != nil {
+		return nil, err
+	}
+	if isLocalSnapshot(snapshot.ID) {
+		defer os.Remove(strings.TrimPrefix(snapshot.ID, localSnapshotPrefix))
+	}
+
+	forkOpts := []Option{
+		func(o *Options) { *o = *c.opt.clone() },
+		WithSandboxID(""),
+		WithUser(c.User),
+		WithSnapshot(snapshot.ID),
+	}
+	if len(c.Template) > 0 {
+		forkOpts = append(forkOpts, WithTemplate(c.Template))
+	}
+	return NewSandbox(ctx, append(forkOpts, opts...)...)
+}
+
+func (c *Sandbox) snapshotLocal(ctx context.Context, name string) (*SnapshotDetail, error) {
+	dir := c.opt.snapshotDir
+	if len(dir) == 0 {
+		dir = os.TempDir()
+	}
+
+	local := filepath.Join(dir, fmt.Sprintf("secvirt-snapshot-%s.tar.gz", uuid.NewString()))
+	f, err := os.Create(local)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// The archive streams out of the sandbox, nothing is left behind in it.
+	cmd := commands.Command(ctx, c, "tar", "-czf", "-", ".")
+	cmd.Dir = c.HomeDir()
+	cmd.Stdout = f
+	if err := runArchive(cmd); err != nil {
+		os.Remove(local)
+		return nil, fmt.Errorf("archive home dir: %w", err)
+	}
+
+	return &SnapshotDetail{
+		ID:        localSnapshotPrefix + local,
+		Name:      name,
+		SandboxID: c.ID,
+		CreateAt:  time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+func (c *Sandbox) restoreLocal(ctx context.Context, snapshotID string) error {
+	f, err := os.Open(strings.TrimPrefix(snapshotID, localSnapshotPrefix))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd := commands.Command(ctx, c, "sh", "-c", "find . -mindepth 1 -delete && tar -xzf -")
+	cmd.Dir = c.HomeDir()
+	cmd.Stdin = f
+	if err := runArchive(cmd); err != nil {
+		return fmt.Errorf("extract snapshot: %w", err)
+	}
+	return nil
+}
+
+// runArchive runs cmd and adds its stderr to the error.
+func runArchive(cmd *commands.ExecCmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package spec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"connectrpc.com/connect"
+)
+
+// Sentinel errors shared by the API, proxy, filesystem and process clients.
+// Match them with errors.Is.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrNotReady      = errors.New("not ready")
+	ErrConflict      = errors.New("conflict")
+	ErrUnauthorized  = errors.New("unauthorized")
+	// ErrRateLimited is a 429: unlike ErrQuotaExceeded, the request may
+	// succeed when sent again later.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrStreamStalled ends a stream that got neither data nor a keepalive
+	// within its idle timeout, e.g. over a half-open proxy connection.
+	ErrStreamStalled = errors.New("stream stalled")
+)
+
+// notReadyMessages are messages the backend sends while a sandbox container
+// is still starting.
+var notReadyMessages = []string{
+	"container waitting",
+	"container waiting",
+}
+
+// transientMessages cover transport failures that reach us as plain strings,
+// e.g. through the openclaw gateway client.
+var transientMessages = []string{
+	"unexpected EOF",
+	"connection reset by peer",
+	"broken pipe",
+}
+
+// StatusError maps an HTTP status and message from the secvirt API or proxy
+// to a sentinel error, or nil if none applies.
+func StatusError(status int, message string) error {
+	for _, m := range notReadyMessages {
+		if strings.Contains(message, m) {
+			return ErrNotReady
+		}
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusInsufficientStorage:
+		return ErrQuotaExceeded
+	case http.StatusServiceUnavailable, http.StatusTooEarly:
+		return ErrNotReady
+	}
+	return nil
+}
+
+// CodeError maps a connect code to a sentinel error, or nil if none applies.
+func CodeError(code connect.Code) error {
+	switch code {
+	case connect.CodeNotFound:
+		return ErrNotFound
+	case connect.CodeUnauthenticated, connect.CodePermissionDenied:
+		return ErrUnauthorized
+	case connect.CodeAlreadyExists, connect.CodeAborted:
+		return ErrConflict
+	case connect.CodeResourceExhausted:
+		return ErrQuotaExceeded
+	case connect.CodeUnavailable:
+		return ErrNotReady
+	}
+	return nil
+}
+
+// IsRetryable reports whether err is worth retrying: the sandbox is not ready
+// yet, the request was rate limited, the server failed, or the connection
+// broke. Cancellation and client
+// errors such as ErrNotFound are not retryable.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, ErrNotReady) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrStreamStalled) {
+		return true
+	}
+	if errors.Is(err, ErrNotFound) ||
+		errors.Is(err, ErrUnauthorized) ||
+		errors.Is(err, ErrConflict) ||
+		errors.Is(err, ErrQuotaExceeded) {
+		return false
+	}
+
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) && statusErr.StatusCode() > 0 {
+		code := statusErr.StatusCode()
+		return (code >= http.StatusInternalServerError && code != http.StatusNotImplemented) ||
+			code == http.StatusTooManyRequests
+	}
+
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		switch connectErr.Code() {
+		case connect.CodeUnknown, connect.CodeInternal, connect.CodeDeadlineExceeded:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	msg := err.Error()
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// connectError keeps the *connect.Error reachable through errors.As while
+// matching the sentinel errors through errors.Is.
+type connectError struct {
+	err *connect.Error
+}
+
+func (e *connectError) Error() string {
+	return e.err.Error()
+}
+
+func (e *connectError) Unwrap() error {
+	return e.err
+}
+
+func (e *connectError) Is(target error) bool {
+	return target != nil && CodeError(e.err.Code()) == target
+}
+
+func wrapConnectError(err error) error {
+	connectErr, ok := err.(*connect.Error)
+	if !ok {
+		return err
+	}
+	return &connectError{err: connectErr}
+}
+
+type errorInterceptor struct{}
+
+// NewErrorInterceptor makes connect errors match the sentinel errors.
+func NewErrorInterceptor() *errorInterceptor {
+	return &errorInterceptor{}
+}
+
+func (i *errorInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		res, err := next(ctx, req)
+		return res, wrapConnectError(err)
+	}
+}
+
+func (i *errorInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		return &errorClientConn{StreamingClientConn: next(ctx, spec)}
+	}
+}
+
+func (i *errorInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+type errorClientConn struct {
+	connect.StreamingClientConn
+}
+
+func (c *errorClientConn) Send(msg any) error {
+	return wrapConnectError(c.StreamingClientConn.Send(msg))
+}
+
+func (c *errorClientConn) Receive(msg any) error {
+	return wrapConnectError(c.StreamingClientConn.Receive(msg))
+}
+
+func (c *errorClientConn) CloseRequest() error {
+	return wrapConnectError(c.StreamingClientConn.CloseRequest())
+}
+
+func (c *errorClientConn) CloseResponse() error {
+	return wrapConnectError(c.StreamingClientConn.CloseResponse())
+}
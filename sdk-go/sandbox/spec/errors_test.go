@@ -0,0 +1,72 @@
+package spec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectErrorMapping(t *testing.T) {
+	err := wrapConnectError(connect.NewError(connect.CodeNotFound, errors.New("no such file")))
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, err, ErrConflict)
+	assert.Equal(t, connect.CodeNotFound, connect.CodeOf(err))
+
+	var connectErr *connect.Error
+	assert.ErrorAs(t, err, &connectErr)
+
+	err = wrapConnectError(connect.NewError(connect.CodeUnavailable, io.ErrUnexpectedEOF))
+	assert.ErrorIs(t, err, ErrNotReady)
+	assert.True(t, IsRetryable(err))
+
+	assert.Equal(t, io.EOF, wrapConnectError(io.EOF))
+	assert.Nil(t, wrapConnectError(nil))
+}
+
+type statusErr int
+
+func (e statusErr) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusErr) StatusCode() int { return int(e) }
+
+func TestIsRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), false},
+		{ErrNotReady, true},
+		{fmt.Errorf("wait: %w", ErrStreamStalled), true},
+		{fmt.Errorf("wrapped: %w", ErrNotFound), false},
+		{ErrQuotaExceeded, false},
+		{fmt.Errorf("create: %w", ErrRateLimited), true},
+		{statusErr(429), true},
+		{statusErr(502), true},
+		{statusErr(501), false},
+		{statusErr(400), false},
+		{wrapConnectError(connect.NewError(connect.CodeInternal, errors.New("boom"))), true},
+		{wrapConnectError(connect.NewError(connect.CodeInvalidArgument, errors.New("bad"))), false},
+		{io.ErrUnexpectedEOF, true},
+		{errors.New("protocol error: incomplete envelope: unexpected EOF"), true},
+		{errors.New("permission denied"), false},
+	} {
+		assert.Equal(t, tc.want, IsRetryable(tc.err), "%v", tc.err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	assert.Equal(t, ErrNotReady, StatusError(500, "container waitting"))
+	assert.Equal(t, ErrNotFound, StatusError(404, ""))
+	assert.Equal(t, ErrUnauthorized, StatusError(403, ""))
+	assert.Equal(t, ErrConflict, StatusError(409, ""))
+	assert.Equal(t, ErrRateLimited, StatusError(429, ""))
+	assert.True(t, IsRetryable(StatusError(429, "")))
+	assert.Equal(t, ErrQuotaExceeded, StatusError(507, ""))
+	assert.Nil(t, StatusError(500, "boom"))
+}
@@ -0,0 +1,45 @@
+package spec
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// IdleTimer calls onStall, typically closing a stream, when Reset was not
+// called for d. A nil IdleTimer, from a d of zero, does nothing.
+type IdleTimer struct {
+	d       time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+func NewIdleTimer(d time.Duration, onStall func()) *IdleTimer {
+	if d <= 0 {
+		return nil
+	}
+
+	t := &IdleTimer{d: d}
+	t.timer = time.AfterFunc(d, func() {
+		t.stalled.Store(true)
+		onStall()
+	})
+	return t
+}
+
+// Reset restarts the timer after data or a keepalive arrived.
+func (t *IdleTimer) Reset() {
+	if t != nil && !t.stalled.Load() {
+		t.timer.Reset(t.d)
+	}
+}
+
+func (t *IdleTimer) Stop() {
+	if t != nil {
+		t.timer.Stop()
+	}
+}
+
+// Stalled reports whether the timer fired.
+func (t *IdleTimer) Stalled() bool {
+	return t != nil && t.stalled.Load()
+}
@@ -0,0 +1,78 @@
+package spec
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type TransportOption func(*http.Transport)
+
+// WithTLSConfig sets the TLS config used for https endpoints.
+func WithTLSConfig(cfg *tls.Config) TransportOption {
+	return func(t *http.Transport) { t.TLSClientConfig = cfg }
+}
+
+// WithH2C speaks HTTP/2 without TLS (prior knowledge) to http endpoints, so
+// connect streams are multiplexed on one connection. The proxy must support it.
+func WithH2C() TransportOption {
+	return func(t *http.Transport) {
+		var protocols http.Protocols
+		protocols.SetHTTP1(false)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		t.Protocols = &protocols
+	}
+}
+
+// WithMaxConnsPerHost bounds the connections to the proxy, 0 means no limit.
+func WithMaxConnsPerHost(n int) TransportOption {
+	return func(t *http.Transport) { t.MaxConnsPerHost = n }
+}
+
+// WithMaxIdleConnsPerHost sets how many idle connections are kept per host.
+func WithMaxIdleConnsPerHost(n int) TransportOption {
+	return func(t *http.Transport) { t.MaxIdleConnsPerHost = n }
+}
+
+// NewTransport returns a transport with the dialer settings used by all
+// sandbox clients. Share one transport across sandboxes to share its
+// connection pool.
+func NewTransport(opts ...TransportOption) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	t := &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConnsPerHost:   runtime.GOMAXPROCS(0) + 1,
+	}
+	for _, o := range opts {
+		o(t)
+	}
+	return t
+}
+
+var defaultTransport = sync.OnceValue(func() *http.Transport {
+	return NewTransport()
+})
+
+// DefaultTransport is the transport shared by every client that was not given
+// its own.
+func DefaultTransport() *http.Transport {
+	return defaultTransport()
+}
+
+// DefaultHTTPClient wraps DefaultTransport.
+func DefaultHTTPClient() *http.Client {
+	return &http.Client{Transport: DefaultTransport()}
+}
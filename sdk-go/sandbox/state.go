@@ -0,0 +1,201 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/llm-infra/secvirt/sdk-go/sandbox/spec"
+	"github.com/mel2oo/go-dkit/ext"
+)
+
+type State string
+
+const (
+	StateCreating  State = "creating"
+	StateRunning   State = "running"
+	StateStopping  State = "stopping"
+	StateStopped   State = "stopped"
+	StateError     State = "error"
+	StateDestroyed State = "destroyed"
+)
+
+var (
+	ErrSandboxStopped   = errors.New("sandbox is stopped")
+	ErrSandboxDestroyed = errors.New("sandbox is destroyed")
+	ErrSandboxFailed    = errors.New("sandbox is in error state")
+	ErrInvalidState     = errors.New("invalid sandbox state transition")
+)
+
+var stateTransitions = map[State][]State{
+	StateCreating:  {StateRunning, StateError, StateDestroyed},
+	StateRunning:   {StateStopping, StateStopped, StateError, StateDestroyed},
+	StateStopping:  {StateStopped, StateError, StateDestroyed},
+	StateStopped:   {StateRunning, StateDestroyed},
+	StateError:     {StateRunning, StateStopped, StateDestroyed},
+	StateDestroyed: {},
+}
+
+// CanTransitionTo reports whether a sandbox in state s may move to state to.
+// States unknown to the SDK are not restricted.
+func (s State) CanTransitionTo(to State) bool {
+	if s == to {
+		return true
+	}
+
+	next, ok := stateTransitions[s]
+	if !ok {
+		return true
+	}
+	for _, n := range next {
+		if n == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (s State) checkTransition(to State) error {
+	if s.CanTransitionTo(to) {
+		return nil
+	}
+	if s == StateDestroyed {
+		return fmt.Errorf("%w: %s -> %s: %w", ErrInvalidState, s, to, ErrSandboxDestroyed)
+	}
+	return fmt.Errorf("%w: %s -> %s", ErrInvalidState, s, to)
+}
+
+type WaitOption func(*WaitOptions)
+
+type WaitOptions struct {
+	noWait      bool
+	minInterval time.Duration
+	maxInterval time.Duration
+}
+
+func newWaitOptions() *WaitOptions {
+	return &WaitOptions{
+		minInterval: 200 * time.Millisecond,
+		maxInterval: 2 * time.Second,
+	}
+}
+
+// WithNoWait makes StartSandbox and StopSandbox return as soon as the request
+// is accepted.
+func WithNoWait() WaitOption {
+	return func(o *WaitOptions) { o.noWait = true }
+}
+
+// WithPollInterval sets the backoff bounds used while polling.
+func WithPollInterval(min, max time.Duration) WaitOption {
+	return func(o *WaitOptions) {
+		o.minInterval = min
+		o.maxInterval = max
+	}
+}
+
+// WaitForState polls the sandbox until it reaches state. It fails early when
+// the sandbox moves to a state from which state is unreachable, and with
+// ErrSandboxFailed when it is in StateError: leaving it takes a StartSandbox.
+func (c *Sandbox) WaitForState(ctx context.Context, state State,
+	opts ...WaitOption) (*SandboxDetail, error) {
+	opt := newWaitOptions()
+	for _, o := range opts {
+		o(opt)
+	}
+
+	return c.waitForState(ctx, state, "", opt)
+}
+
+// waitForState waits for state after a request made in state from. A sandbox
+// that was in StateError may still report it until the request takes effect,
+// so StateError only fails the wait once the sandbox has left it.
+func (c *Sandbox) waitForState(ctx context.Context, state, from State,
+	opt *WaitOptions) (*SandboxDetail, error) {
+	left := from != StateError
+	interval := opt.minInterval
+	for {
+		res, err := c.GetSandbox(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if res.State == state {
+			return res, nil
+		}
+		if res.State != StateError {
+			left = true
+		} else if left {
+			return nil, fmt.Errorf("wait for %s: %w", state, ErrSandboxFailed)
+		}
+		if err := res.State.checkTransition(state); err != nil {
+			return nil, err
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+		interval = min(interval*2, opt.maxInterval)
+	}
+}
+
+// WaitReady waits until the sandbox is running and every health port answers
+// through the proxy.
+func (c *Sandbox) WaitReady(ctx context.Context, opts ...WaitOption) error {
+	opt := newWaitOptions()
+	for _, o := range opts {
+		o(opt)
+	}
+
+	res, err := c.waitForState(ctx, StateRunning, "", opt)
+	if err != nil {
+		return err
+	}
+
+	for _, port := range res.HealthPorts {
+		interval := opt.minInterval
+		for !c.probePort(ctx, port) {
+			if err := sleep(ctx, interval); err != nil {
+				return fmt.Errorf("wait health port %d: %w", port, err)
+			}
+			interval = min(interval*2, opt.maxInterval)
+		}
+	}
+
+	return nil
+}
+
+// probePort sends a single request to port through the proxy, bypassing the
+// retries of the proxy client. Anything but a 5xx from the proxy counts as an
+// answer.
+func (c *Sandbox) probePort(ctx context.Context, port int) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.ProxyBaseURL()+"/", nil)
+	if err != nil {
+		return false
+	}
+	for k, v := range spec.GenSandboxHeader(port, c.Name, c.User) {
+		req.Header.Set(k, v)
+	}
+	ext.InjectHeader(ctx, req.Header)
+
+	resp, err := c.proxy.GetClient().Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}